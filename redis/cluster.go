@@ -0,0 +1,256 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRedirects is the maximum number of MOVED and ASK redirects followed for a
+// command.
+const maxRedirects = 16
+
+var errClusterClosed = errors.New("redigo: use of closed connection")
+
+var errClusterPipeline = errors.New("redigo: ClusterConn does not support Send, Flush and Receive")
+
+// ClusterConn executes commands on a Redis Cluster. ClusterConn sends a command
+// to the node that serves the hash slot of the command's first argument. The
+// mapping from slots to nodes is learned from the MOVED redirects returned by
+// the cluster. Commands for slots with an unknown node are sent to the node at
+// the address passed to NewClusterConn.
+//
+// When a node replies with a MOVED redirect, ClusterConn records the new node
+// for the slot and reissues the command to that node. When a node replies with
+// an ASK redirect, ClusterConn sends ASKING followed by the command to the
+// indicated node without updating the slot mapping.
+//
+// ClusterConn implements the Conn, ConnWithTimeout and ConnWithContext
+// interfaces, but only the Do, DoWithTimeout and DoContext methods execute
+// commands. The Send, Flush and Receive methods return an error because the
+// commands in a pipeline can be served by different nodes. The connection
+// methods cannot be called concurrently.
+type ClusterConn struct {
+	addr    string
+	options []DialOption
+	conns   map[string]Conn
+	slots   map[int]string
+	closed  bool
+}
+
+// NewClusterConn returns a connection to the cluster containing the node at
+// addr. Connections to the nodes are dialed on demand with the specified
+// options.
+func NewClusterConn(addr string, options ...DialOption) *ClusterConn {
+	return &ClusterConn{
+		addr:    addr,
+		options: options,
+		conns:   make(map[string]Conn),
+		slots:   make(map[int]string),
+	}
+}
+
+// node returns the connection to the node at addr, dialing the node if
+// necessary.
+func (cc *ClusterConn) node(addr string) (Conn, error) {
+	if c, ok := cc.conns[addr]; ok {
+		if c.Err() == nil {
+			return c, nil
+		}
+		c.Close()
+		delete(cc.conns, addr)
+	}
+	c, err := Dial("tcp", addr, cc.options...)
+	if err != nil {
+		return nil, err
+	}
+	cc.conns[addr] = c
+	return c, nil
+}
+
+// Close closes the connections to all nodes.
+func (cc *ClusterConn) Close() error {
+	cc.closed = true
+	var err error
+	for addr, c := range cc.conns {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+		delete(cc.conns, addr)
+	}
+	return err
+}
+
+// Err returns a non-nil value after the connection is closed. Failures of
+// individual nodes are returned by Do.
+func (cc *ClusterConn) Err() error {
+	if cc.closed {
+		return errClusterClosed
+	}
+	return nil
+}
+
+// Do sends a command to the node that serves the slot of the command's first
+// argument and returns the reply. Do follows MOVED and ASK redirects.
+func (cc *ClusterConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return cc.do(args, func(c Conn) (interface{}, error) {
+		return c.Do(cmd, args...)
+	})
+}
+
+// DoWithTimeout executes a command as Do with the specified read timeout.
+func (cc *ClusterConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	return cc.do(args, func(c Conn) (interface{}, error) {
+		return DoWithTimeout(c, timeout, cmd, args...)
+	})
+}
+
+// DoContext executes a command as Do with the specified context.
+// Cancellation closes the connection to the node executing the command.
+func (cc *ClusterConn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	return cc.do(args, func(c Conn) (interface{}, error) {
+		return DoContext(c, ctx, cmd, args...)
+	})
+}
+
+// do executes a command on the node that serves the slot of args[0] using f
+// and follows redirects.
+func (cc *ClusterConn) do(args []interface{}, f func(c Conn) (interface{}, error)) (interface{}, error) {
+	if cc.closed {
+		return nil, errClusterClosed
+	}
+	addr := cc.addr
+	if len(args) > 0 {
+		if key, ok := clusterKey(args[0]); ok {
+			if a, ok := cc.slots[Slot(key)]; ok {
+				addr = a
+			}
+		}
+	}
+	asking := false
+	for i := 0; ; i++ {
+		c, err := cc.node(addr)
+		if err != nil {
+			return nil, err
+		}
+		if asking {
+			if err := c.Send("ASKING"); err != nil {
+				return nil, err
+			}
+		}
+		reply, err := f(c)
+		e, ok := err.(Error)
+		if !ok {
+			return reply, err
+		}
+		kind, slot, redirect, ok := parseRedirect(e, addr)
+		if !ok {
+			return reply, err
+		}
+		if i >= maxRedirects {
+			return nil, errors.New("redigo: too many cluster redirects")
+		}
+		addr = redirect
+		asking = kind == "ASK"
+		if !asking {
+			cc.slots[slot] = redirect
+		}
+	}
+}
+
+// Send returns an error. ClusterConn does not support pipelining.
+func (cc *ClusterConn) Send(cmd string, args ...interface{}) error {
+	return errClusterPipeline
+}
+
+// Flush returns an error. ClusterConn does not support pipelining.
+func (cc *ClusterConn) Flush() error {
+	return errClusterPipeline
+}
+
+// Receive returns an error. ClusterConn does not support pipelining.
+func (cc *ClusterConn) Receive() (interface{}, error) {
+	return nil, errClusterPipeline
+}
+
+// clusterKey returns the key for an argument of type string or []byte.
+func clusterKey(arg interface{}) (string, bool) {
+	switch arg := arg.(type) {
+	case string:
+		return arg, true
+	case []byte:
+		return string(arg), true
+	}
+	return "", false
+}
+
+// parseRedirect parses a MOVED or ASK error of the form "MOVED slot addr"
+// returned by the node at from. The server omits the host from addr when the
+// host is not known to the server; the host of from is used in that case.
+func parseRedirect(e Error, from string) (kind string, slot int, addr string, ok bool) {
+	f := strings.Fields(string(e))
+	if len(f) != 3 || (f[0] != "MOVED" && f[0] != "ASK") {
+		return "", 0, "", false
+	}
+	slot, err := strconv.Atoi(f[1])
+	if err != nil {
+		return "", 0, "", false
+	}
+	addr = f[2]
+	if strings.HasPrefix(addr, ":") {
+		host, _, err := net.SplitHostPort(from)
+		if err != nil {
+			return "", 0, "", false
+		}
+		addr = net.JoinHostPort(host, addr[1:])
+	}
+	return f[0], slot, addr, true
+}
+
+// Slot returns the Redis Cluster hash slot for key. The slot is the CRC16 of
+// the key modulo 16384. If the key contains a hash tag, a non-empty substring
+// between the first { and the following }, then only the hash tag is hashed.
+// Use hash tags to place related keys in the same slot:
+//
+//  redis.Slot("{user:1000}.following") == redis.Slot("{user:1000}.followers")
+func Slot(key string) int {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			key = key[i+1 : i+1+j]
+		}
+	}
+	return int(crc16(key) % 16384)
+}
+
+// crc16 computes the CRC16-CCITT (XMODEM) checksum used by Redis Cluster.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
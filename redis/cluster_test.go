@@ -0,0 +1,169 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestClusterConn(t *testing.T) {
+	b := newFakeServer(t, func(args []string) string {
+		if args[0] == "GET" {
+			return "$3\r\nbar\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer b.close()
+	a := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "GET":
+			// The slot of foo is 12182.
+			return "-MOVED 12182 " + b.addr() + "\r\n"
+		case "SET":
+			return "-ASK 5061 " + b.addr() + "\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer a.close()
+
+	c := redis.NewClusterConn(a.addr())
+	defer c.Close()
+
+	for i := 0; i < 2; i++ {
+		v, err := redis.String(c.Do("GET", "foo"))
+		if err != nil || v != "bar" {
+			t.Fatalf("Do(GET foo) = %q, %v, want bar, nil", v, err)
+		}
+	}
+	// The MOVED redirect is cached, so the second GET is sent to b.
+	if actual, expected := a.received(), [][]string{{"GET", "foo"}}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("a commands = %v, want %v", actual, expected)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Do("SET", "baz", "qux"); err != nil {
+			t.Fatalf("Do(SET baz) returned %v", err)
+		}
+	}
+	// The ASK redirect is not cached.
+	expected := [][]string{{"GET", "foo"}, {"SET", "baz", "qux"}, {"SET", "baz", "qux"}}
+	if actual := a.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("a commands = %v, want %v", actual, expected)
+	}
+	expected = [][]string{{"GET", "foo"}, {"GET", "foo"}, {"ASKING"}, {"SET", "baz", "qux"}, {"ASKING"}, {"SET", "baz", "qux"}}
+	if actual := b.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("b commands = %v, want %v", actual, expected)
+	}
+
+	if err := c.Send("GET", "foo"); err == nil {
+		t.Error("Send returned nil error")
+	}
+	c.Close()
+	if _, err := c.Do("GET", "foo"); err == nil {
+		t.Error("Do after Close returned nil error")
+	}
+}
+
+func TestClusterConnEmptyRedirectHost(t *testing.T) {
+	b := newFakeServer(t, func(args []string) string {
+		return "$3\r\nbar\r\n"
+	})
+	defer b.close()
+	_, port, err := net.SplitHostPort(b.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newFakeServer(t, func(args []string) string {
+		// The host of b is the host of a.
+		return "-MOVED 12182 :" + port + "\r\n"
+	})
+	defer a.close()
+
+	var dialed []string
+	c := redis.NewClusterConn(a.addr(), redis.DialNetDial(func(network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return net.Dial(network, addr)
+	}))
+	defer c.Close()
+
+	v, err := redis.String(c.Do("GET", "foo"))
+	if err != nil || v != "bar" {
+		t.Fatalf("Do(GET foo) = %q, %v, want bar, nil", v, err)
+	}
+	if expected := []string{a.addr(), b.addr()}; !reflect.DeepEqual(dialed, expected) {
+		t.Errorf("dialed %v, want %v", dialed, expected)
+	}
+}
+
+func TestClusterConnTimeoutContext(t *testing.T) {
+	b := newFakeServer(t, func(args []string) string {
+		if args[0] == "GET" {
+			return "$3\r\nbar\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer b.close()
+	a := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "GET":
+			return "-MOVED 12182 " + b.addr() + "\r\n"
+		case "SET":
+			return "-ASK 5061 " + b.addr() + "\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer a.close()
+
+	c := redis.NewClusterConn(a.addr())
+	defer c.Close()
+
+	if v, err := redis.String(redis.DoWithTimeout(c, time.Second, "GET", "foo")); err != nil || v != "bar" {
+		t.Errorf("DoWithTimeout(GET foo) = %q, %v, want bar, nil", v, err)
+	}
+	if _, err := redis.DoContext(c, context.Background(), "SET", "baz", "qux"); err != nil {
+		t.Errorf("DoContext(SET baz) returned %v", err)
+	}
+	expected := [][]string{{"GET", "foo"}, {"ASKING"}, {"SET", "baz", "qux"}}
+	if actual := b.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("b commands = %v, want %v", actual, expected)
+	}
+}
+
+func TestSlot(t *testing.T) {
+	tests := []struct {
+		key  string
+		slot int
+	}{
+		{"123456789", 12739},
+		{"foo", 12182},
+		{"bar", 5061},
+		{"", 0},
+		{"{user1000}.following", 3443},
+		{"foo{}{bar}", 8363},
+		{"foo{{bar}}zap", 4015},
+		{"foo{bar}{zap}", 5061},
+	}
+	for _, tt := range tests {
+		if slot := redis.Slot(tt.key); slot != tt.slot {
+			t.Errorf("Slot(%q) = %d, want %d", tt.key, slot, tt.slot)
+		}
+	}
+}
@@ -16,15 +16,20 @@ package redis
 
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math/big"
 	"net"
+	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
-    "log"
 )
 
 // conn is the low-level implementation of Conn
@@ -32,54 +37,480 @@ type conn struct {
 	conn net.Conn
 
 	// Read
-	readTimeout time.Duration
-	br          *bufio.Reader
-	scratch     []byte
+	readTimeout     time.Duration
+	readDeadlineSet bool
+	br              *bufio.Reader
+	scratch         []byte
 
 	// Write
-	writeTimeout time.Duration
-	bw           *bufio.Writer
+	writeTimeout     time.Duration
+	writeDeadlineSet bool
+	bw               *bufio.Writer
 
 	// Shared
 	mu      sync.Mutex
 	pending int
 	err     error
+
+	// bulk is the unread payload of a reply returned from ReceiveReader.
+	bulk *bulkReader
+
+	// onPush is the handler for RESP3 push messages.
+	onPush func([]interface{})
+}
+
+// DialOption specifies an option for dialing a Redis server.
+type DialOption struct {
+	f func(*dialOptions)
+}
+
+type dialOptions struct {
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	connectTimeout  time.Duration
+	keepAlive       time.Duration
+	readBufferSize  int
+	writeBufferSize int
+	password        string
+	db              int
+	clientName      string
+	useRESP3        bool
+	onConnect       []func(Conn) error
+	netDial         func(network, addr string) (net.Conn, error)
+	verifyRole      bool
+	setNoDelay      bool
+	noDelay         bool
+}
+
+// DialConnectTimeout specifies the timeout for connecting to the Redis server.
+// If the value is zero, then the operating system timeout applies.
+func DialConnectTimeout(d time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.connectTimeout = d
+	}}
+}
+
+// DialKeepAlive specifies the TCP keep-alive period for the connection. If
+// the value is greater than zero, then keep-alive probes are enabled on the
+// underlying TCP connection with the given period. The option is ignored for
+// networks other than TCP.
+func DialKeepAlive(d time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.keepAlive = d
+	}}
+}
+
+// DialNetDial specifies a custom dial function for creating the network
+// connection. Use DialNetDial to connect through a proxy:
+//
+//  dialer, err := proxy.SOCKS5("tcp", "proxy:1080", nil, proxy.Direct)
+//  ...
+//  c, err := redis.Dial("tcp", "redis:6379", redis.DialNetDial(dialer.Dial))
+//
+// The DialConnectTimeout option and the context passed to DialContext do not
+// apply to the dial function. The DialKeepAlive and DialNoDelay options apply
+// if the dial function returns a *net.TCPConn. All other options apply to the
+// returned connection.
+func DialNetDial(dial func(network, addr string) (net.Conn, error)) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.netDial = dial
+	}}
+}
+
+// DialNoDelay specifies whether the operating system should delay sending
+// data on the TCP connection in the hope of sending fewer packets (Nagle's
+// algorithm). Go disables Nagle's algorithm on TCP connections by default. The
+// option is ignored for networks other than TCP.
+func DialNoDelay(noDelay bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.setNoDelay = true
+		do.noDelay = noDelay
+	}}
+}
+
+// DialReadTimeout specifies the timeout for reading a single command reply.
+// The deadline is set before each read, so the timeout applies to each
+// operation and not to the lifetime of the connection.
+func DialReadTimeout(d time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.readTimeout = d
+	}}
+}
+
+// DialWriteTimeout specifies the timeout for writing a single command. The
+// deadline is set before each write, so the timeout applies to each operation
+// and not to the lifetime of the connection.
+func DialWriteTimeout(d time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.writeTimeout = d
+	}}
+}
+
+// DialReadBufferSize specifies the size of the buffer used to read replies
+// from the server. If the value is zero, then a default size of 4096 bytes is
+// used. The buffer must hold the longest status, error or length line in a
+// reply. Bulk values are not limited by the buffer size. Sizes below 512 bytes
+// risk "long response line" errors on long error replies.
+func DialReadBufferSize(n int) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.readBufferSize = n
+	}}
+}
+
+// DialWriteBufferSize specifies the size of the buffer used to write commands
+// to the server. If the value is zero, then a default size of 4096 bytes is
+// used. Commands larger than the buffer are written in multiple chunks, so any
+// size is correct, but small buffers increase the number of writes to the
+// network.
+func DialWriteBufferSize(n int) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.writeBufferSize = n
+	}}
 }
 
-// Dial connects to the Redis server at the given network and address.
-func Dial(network, address string) (Conn, error) {
-	c, err := net.Dial(network, address)
+// DialPassword specifies the password to use when connecting to the Redis
+// server. The password is sent with the AUTH command before the connection is
+// returned to the application.
+func DialPassword(password string) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.password = password
+	}}
+}
+
+// DialDatabase specifies the database to select when dialing a connection.
+// The database is selected with the SELECT command before the connection is
+// returned to the application.
+func DialDatabase(db int) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.db = db
+	}}
+}
+
+// DialUseRESP3 specifies that the connection uses the RESP3 protocol. The
+// protocol is selected with the HELLO 3 command when the connection is dialed.
+// Dial returns an error if the server does not support the HELLO command or the
+// RESP3 protocol. The reply helpers and the Scan functions convert RESP3
+// replies as described in the package documentation. Applications that use type
+// assertions on reply values must also handle the RESP3 double, boolean and big
+// number types.
+func DialUseRESP3() DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.useRESP3 = true
+	}}
+}
+
+// DialClientName specifies a client name to set with the CLIENT SETNAME
+// command when dialing a connection. The name is shown in the output of the
+// CLIENT LIST command. Dial returns an error if the server rejects the
+// command.
+func DialClientName(name string) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.clientName = name
+	}}
+}
+
+// DialOnConnect specifies a function to run on a new connection after the
+// connection is authenticated and the database is selected. Use DialOnConnect
+// to run setup commands such as CLIENT SETNAME. If the function returns an
+// error, then the network connection is closed and dial returns the error.
+// Functions specified with multiple DialOnConnect options run in order.
+func DialOnConnect(f func(c Conn) error) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.onConnect = append(do.onConnect, f)
+	}}
+}
+
+// Dial connects to the Redis server at the given network and address using
+// the specified options.
+func Dial(network, address string, options ...DialOption) (Conn, error) {
+	return DialContext(context.Background(), network, address, options...)
+}
+
+// DialContext connects to the Redis server at the given network and address
+// using the specified options and context. The context bounds establishing
+// the network connection and the setup commands specified by the options. The
+// context does not apply to the connection after DialContext returns.
+func DialContext(ctx context.Context, network, address string, options ...DialOption) (Conn, error) {
+	do := dialOptions{}
+	for _, option := range options {
+		option.f(&do)
+	}
+	c, err := do.dial(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return newConnWithOptions(ctx, c, &do)
+}
+
+// dial establishes the network connection specified by the options.
+func (do *dialOptions) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	var c net.Conn
+	var err error
+	if do.netDial != nil {
+		c, err = do.netDial(network, address)
+	} else {
+		d := net.Dialer{Timeout: do.connectTimeout}
+		c, err = d.DialContext(ctx, network, address)
+	}
 	if err != nil {
 		return nil, errors.New("Could not connect to Redis server: " + err.Error())
 	}
-	return NewConn(c, 0, 0), nil
+	if tc, ok := c.(*net.TCPConn); ok {
+		if err := do.setTCPOptions(tc); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// setTCPOptions applies the TCP socket options specified by the options.
+func (do *dialOptions) setTCPOptions(tc *net.TCPConn) error {
+	if do.keepAlive > 0 {
+		if err := tc.SetKeepAlive(true); err != nil {
+			return err
+		}
+		if err := tc.SetKeepAlivePeriod(do.keepAlive); err != nil {
+			return err
+		}
+	}
+	if do.setNoDelay {
+		if err := tc.SetNoDelay(do.noDelay); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DialUnix connects to the Redis server listening on the Unix domain socket at
+// path using the specified options. Read and write timeouts apply to the
+// connection the same way they do for TCP connections.
+func DialUnix(path string, options ...DialOption) (Conn, error) {
+	return Dial("unix", path, options...)
+}
+
+// newConnWithOptions returns a connection for netConn after running the
+// connection setup commands specified by do. The setup commands are bounded
+// by ctx. The network connection is closed if a setup command fails.
+func newConnWithOptions(ctx context.Context, netConn net.Conn, do *dialOptions) (Conn, error) {
+	c := newConn(netConn, do)
+
+	if do.password != "" {
+		if _, err := c.DoContext(ctx, "AUTH", do.password); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	if do.useRESP3 {
+		if _, err := c.DoContext(ctx, "HELLO", 3); err != nil {
+			netConn.Close()
+			if _, ok := err.(Error); ok {
+				return nil, fmt.Errorf("redigo: server does not support RESP3: %v", err)
+			}
+			return nil, err
+		}
+	}
+
+	if do.db != 0 {
+		if _, err := c.DoContext(ctx, "SELECT", do.db); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	if do.clientName != "" {
+		if _, err := c.DoContext(ctx, "CLIENT", "SETNAME", do.clientName); err != nil {
+			netConn.Close()
+			if _, ok := err.(Error); ok {
+				return nil, fmt.Errorf("redigo: CLIENT SETNAME failed: %v", err)
+			}
+			return nil, err
+		}
+	}
+
+	for _, f := range do.onConnect {
+		if err := f(c); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
 }
 
 // DialTimeout acts like Dial but takes timeouts for establishing the
 // connection to the server, writing a command and reading a reply.
 func DialTimeout(network, address string, connectTimeout, readTimeout, writeTimeout time.Duration) (Conn, error) {
-	var c net.Conn
-	var err error
-	if connectTimeout > 0 {
-		c, err = net.DialTimeout(network, address, connectTimeout)
-	} else {
-		c, err = net.Dial(network, address)
+	return Dial(network, address,
+		DialConnectTimeout(connectTimeout),
+		DialReadTimeout(readTimeout),
+		DialWriteTimeout(writeTimeout))
+}
+
+// DialTLS connects to the Redis server at the given network and address
+// using TLS. If config is nil, then the default configuration is used. If the
+// configuration does not specify a server name, then the host from address is
+// used to verify the server certificate. The DialConnectTimeout option bounds
+// establishing the network connection and the TLS handshake.
+func DialTLS(network, address string, config *tls.Config, options ...DialOption) (Conn, error) {
+	do := dialOptions{}
+	for _, option := range options {
+		option.f(&do)
+	}
+	ctx := context.Background()
+	if do.connectTimeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, do.connectTimeout)
+		defer cancel()
 	}
+	c, err := do.dial(ctx, network, address)
 	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		config = config.Clone()
+		config.ServerName = host
+	}
+	tlsConn := tls.Client(c, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		c.Close()
 		return nil, errors.New("Could not connect to Redis server: " + err.Error())
 	}
-	return NewConn(c, readTimeout, writeTimeout), nil
+	return newConnWithOptions(context.Background(), tlsConn, &do)
 }
 
-// NewConn returns a new Redigo connection for the given net connection.
+var pathDBRegexp = regexp.MustCompile(`/(\d*)\z`)
+
+// DialURL connects to a Redis server at the given URL using the Redis URI
+// scheme. URLs should follow the draft IANA specification for the scheme
+// (https://www.iana.org/assignments/uri-schemes/prov/redis). The rediss
+// scheme connects to the server using TLS. The password and database in the
+// URL override the DialPassword and DialDatabase options.
+//
+//  redis://:password@host:port/db
+func DialURL(rawurl string, options ...DialOption) (Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("redigo: invalid redis URL scheme: %s", u.Scheme)
+	}
+
+	// As per the IANA draft spec, the host defaults to localhost and
+	// the port defaults to 6379.
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		// assume port is missing
+		host = u.Host
+		port = "6379"
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	address := net.JoinHostPort(host, port)
+
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			options = append(options, DialPassword(password))
+		}
+	}
+
+	match := pathDBRegexp.FindStringSubmatch(u.Path)
+	if len(match) == 2 {
+		if len(match[1]) > 0 {
+			db, err := strconv.Atoi(match[1])
+			if err != nil {
+				return nil, fmt.Errorf("redigo: invalid database: %s", u.Path[1:])
+			}
+			options = append(options, DialDatabase(db))
+		}
+	} else if u.Path != "" {
+		return nil, fmt.Errorf("redigo: invalid database: %s", u.Path[1:])
+	}
+
+	if u.Scheme == "rediss" {
+		return DialTLS("tcp", address, nil, options...)
+	}
+	return Dial("tcp", address, options...)
+}
+
+// DoOnce connects to the Redis server at the given network and address,
+// executes a single command, closes the connection and returns the reply. Use
+// DoOnce for health checks and command line tools that execute one command.
+// Applications that execute more than one command should use a Pool.
+func DoOnce(network, address, cmd string, args ...interface{}) (interface{}, error) {
+	c, err := Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.Do(cmd, args...)
+}
+
+// NewConn returns a new Redigo connection for the given net connection. Use
+// NewConn to wrap a network connection established by the application, for
+// example a connection through a proxy. The connection buffers reads and
+// writes. The read timeout applies to each reply and the write timeout applies
+// to each command. A zero timeout disables the timeout.
 func NewConn(netConn net.Conn, readTimeout, writeTimeout time.Duration) Conn {
+	return newConn(netConn, &dialOptions{readTimeout: readTimeout, writeTimeout: writeTimeout})
+}
+
+func newConn(netConn net.Conn, do *dialOptions) *conn {
+	readBufferSize := do.readBufferSize
+	if readBufferSize <= 0 {
+		readBufferSize = 4096
+	}
+	writeBufferSize := do.writeBufferSize
+	if writeBufferSize <= 0 {
+		writeBufferSize = 4096
+	}
 	return &conn{
 		conn:         netConn,
-		bw:           bufio.NewWriter(netConn),
-		br:           bufio.NewReader(netConn),
-		readTimeout:  readTimeout,
-		writeTimeout: writeTimeout,
+		bw:           bufio.NewWriterSize(netConn, writeBufferSize),
+		br:           bufio.NewReaderSize(netConn, readBufferSize),
+		readTimeout:  do.readTimeout,
+		writeTimeout: do.writeTimeout,
+	}
+}
+
+// OnPush sets the handler for RESP3 push messages. The connection calls the
+// handler from the goroutine reading replies when a push message precedes a
+// reply. OnPush must not be called concurrently with methods that read from
+// the server.
+func (c *conn) OnPush(f func(push []interface{})) {
+	c.onPush = f
+}
+
+// NetConn returns the underlying network connection. Setting options on the
+// network connection concurrently with a command is not safe. Reading from or
+// writing to the network connection directly corrupts the Redis protocol
+// stream.
+func (c *conn) NetConn() net.Conn {
+	return c.conn
+}
+
+// Ping sends the PING command and checks for the PONG reply. A connection
+// error or an unexpected reply is latched in the connection.
+func (c *conn) Ping() error {
+	reply, err := c.Do("PING")
+	if err != nil {
+		return err
+	}
+	if s, ok := reply.(string); !ok || s != "PONG" {
+		return c.fatal(fmt.Errorf("redigo: unexpected reply to PING: %v", reply))
 	}
+	return nil
 }
 
 func (c *conn) Close() error {
@@ -108,55 +539,41 @@ func (c *conn) Err() error {
 	return err
 }
 
-func (c *conn) writeN(prefix byte, n int) error {
-	c.scratch = append(c.scratch[0:0], prefix)
-	c.scratch = strconv.AppendInt(c.scratch, int64(n), 10)
-	c.scratch = append(c.scratch, "\r\n"...)
-	_, err := c.bw.Write(c.scratch)
-	return err
-}
-
-func (c *conn) writeString(s string) error {
-	c.writeN('$', len(s))
-	c.bw.WriteString(s)
-	_, err := c.bw.WriteString("\r\n")
-	return err
+// setReadDeadline sets the read deadline for the next operation. A zero
+// timeout clears a deadline left by a previous operation. Deadlines set
+// outside of the connection are not cleared.
+func (c *conn) setReadDeadline(timeout time.Duration) {
+	if c.conn == nil {
+		return
+	}
+	if timeout != 0 {
+		c.conn.SetReadDeadline(time.Now().Add(timeout))
+		c.readDeadlineSet = true
+	} else if c.readDeadlineSet {
+		c.conn.SetReadDeadline(time.Time{})
+		c.readDeadlineSet = false
+	}
 }
 
-func (c *conn) writeBytes(p []byte) error {
-	c.writeN('$', len(p))
-	c.bw.Write(p)
-	_, err := c.bw.WriteString("\r\n")
-	return err
+// setWriteDeadline sets the write deadline for the next operation. A zero
+// timeout clears a deadline left by a previous operation. Deadlines set
+// outside of the connection are not cleared.
+func (c *conn) setWriteDeadline(timeout time.Duration) {
+	if c.conn == nil {
+		return
+	}
+	if timeout != 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(timeout))
+		c.writeDeadlineSet = true
+	} else if c.writeDeadlineSet {
+		c.conn.SetWriteDeadline(time.Time{})
+		c.writeDeadlineSet = false
+	}
 }
 
-func (c *conn) writeCommand(cmd string, args []interface{}) (err error) {
-	c.writeN('*', 1+len(args))
-	err = c.writeString(cmd)
-	for _, arg := range args {
-		if err != nil {
-			break
-		}
-		switch arg := arg.(type) {
-		case string:
-			err = c.writeString(arg)
-		case []byte:
-			err = c.writeBytes(arg)
-		case bool:
-			if arg {
-				err = c.writeString("1")
-			} else {
-				err = c.writeString("0")
-			}
-		case nil:
-			err = c.writeString("")
-		default:
-			var buf bytes.Buffer
-			fmt.Fprint(&buf, arg)
-			err = c.writeBytes(buf.Bytes())
-		}
-	}
-    log.Println("Error writing command to write buffer: ", err)
+func (c *conn) writeCommand(cmd string, args []interface{}) error {
+	c.scratch = appendCommand(c.scratch[:0], cmd, args)
+	_, err := c.bw.Write(c.scratch)
 	return err
 }
 
@@ -176,10 +593,74 @@ func (c *conn) readLine() ([]byte, error) {
 }
 
 func (c *conn) readReply() (interface{}, error) {
+	_, reply, err := c.readReplyKind()
+	return reply, err
+}
+
+// readReplyKind reads a reply and returns the reply with the type byte of the
+// reply.
+func (c *conn) readReplyKind() (byte, interface{}, error) {
+	if err := c.discardBulk(); err != nil {
+		return 0, nil, err
+	}
+	line, err := c.readReplyLine()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(line) == 0 {
+		return 0, nil, errors.New("redigo: short response line")
+	}
+	kind := line[0]
+	reply, err := c.parseReply(line)
+	return kind, reply, err
+}
+
+// readReplyLine reads the first line of the next reply. Push messages
+// preceding the reply are delivered to the push handler.
+func (c *conn) readReplyLine() ([]byte, error) {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '>' || c.onPush == nil {
+			return line, nil
+		}
+		reply, err := c.parseReply(line)
+		if err != nil {
+			return nil, err
+		}
+		// A null push frame has no message to deliver.
+		if push, ok := reply.([]interface{}); ok {
+			c.onPush(push)
+		}
+	}
+}
+
+// readElement reads an element of an aggregate reply.
+func (c *conn) readElement() (interface{}, error) {
 	line, err := c.readLine()
 	if err != nil {
 		return nil, err
 	}
+	return c.parseReply(line)
+}
+
+// readElements reads the n elements of an aggregate reply and returns the
+// elements as a []interface{}.
+func (c *conn) readElements(n int) (interface{}, error) {
+	r := make([]interface{}, n)
+	for i := range r {
+		var err error
+		r[i], err = c.readElement()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (c *conn) parseReply(line []byte) (interface{}, error) {
 	if len(line) == 0 {
 		return nil, errors.New("redigo: short response line")
 	}
@@ -199,53 +680,154 @@ func (c *conn) readReply() (interface{}, error) {
 		if err != nil || n < 0 {
 			return nil, err
 		}
-		p := make([]byte, n)
-		_, err = io.ReadFull(c.br, p)
+		return c.readBulk(n)
+	case '=':
+		// Verbatim strings are returned as bulk values without the format
+		// prefix so that the String and Bytes helpers work with RESP2 and
+		// RESP3 replies.
+		n, err := strconv.Atoi(string(line[1:]))
 		if err != nil {
 			return nil, err
 		}
-		line, err := c.readLine()
+		p, err := c.readBulk(n)
 		if err != nil {
 			return nil, err
 		}
-		if len(line) != 0 {
-			return nil, errors.New("redigo: bad bulk format")
+		if len(p) < 4 || p[3] != ':' {
+			return nil, fmt.Errorf("redigo: protocol error: bad verbatim string %q", truncateLine(p))
+		}
+		return p[4:], nil
+	case '_':
+		// The null is returned as nil, the value of RESP2 nil bulk and
+		// multi-bulk replies, so that the helpers return ErrNil with RESP2
+		// and RESP3 replies.
+		if len(line) != 1 {
+			return nil, fmt.Errorf("redigo: protocol error: bad null reply %q", truncateLine(line))
 		}
-		return p, nil
-	case '*':
+		return nil, nil
+	case '%':
+		// Maps are flattened to alternating keys and values so that the
+		// StringMap, IntMap, Int64Map and ScanStruct helpers work with RESP2
+		// and RESP3 replies.
 		n, err := strconv.Atoi(string(line[1:]))
 		if err != nil || n < 0 {
 			return nil, err
 		}
-		r := make([]interface{}, n)
-		for i := range r {
-			r[i], err = c.readReply()
-			if err != nil {
-				return nil, err
-			}
+		return c.readElements(2 * n)
+	case ',':
+		f, err := strconv.ParseFloat(string(line[1:]), 64)
+		if err != nil {
+			return nil, err
 		}
-		return r, nil
+		return f, nil
+	case '(':
+		n, ok := new(big.Int).SetString(string(line[1:]), 10)
+		if !ok {
+			return nil, fmt.Errorf("redigo: protocol error: bad big number reply %q", truncateLine(line))
+		}
+		return n, nil
+	case '#':
+		switch string(line[1:]) {
+		case "t":
+			return true, nil
+		case "f":
+			return false, nil
+		}
+		return nil, fmt.Errorf("redigo: protocol error: bad boolean reply %q", truncateLine(line))
+	case '*', '>':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		return c.readElements(n)
+	case '~':
+		// Sets are returned as arrays so that the Strings, Values and other
+		// slice helpers work with RESP2 and RESP3 replies.
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("redigo: protocol error: bad set reply %q", truncateLine(line))
+		}
+		return c.readElements(n)
+	}
+	return nil, fmt.Errorf("redigo: protocol error: unexpected response line %q (type byte %q), possible reply desync", truncateLine(line), line[0])
+}
+
+// readBulk reads a bulk payload of n bytes and the following CRLF.
+func (c *conn) readBulk(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, errors.New("redigo: bad bulk length")
+	}
+	p := make([]byte, n)
+	if _, err := io.ReadFull(c.br, p); err != nil {
+		return nil, err
+	}
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
 	}
-	return nil, errors.New("redigo: unpexected response line")
+	if len(line) != 0 {
+		return nil, errors.New("redigo: bad bulk format")
+	}
+	return p, nil
+}
+
+// truncateLine shortens a response line for inclusion in an error message.
+func truncateLine(line []byte) []byte {
+	const max = 32
+	if len(line) > max {
+		return line[:max]
+	}
+	return line
 }
 
 func (c *conn) Send(cmd string, args ...interface{}) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
 	c.mu.Lock()
 	c.pending += 1
 	c.mu.Unlock()
-	if c.writeTimeout != 0 {
-		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
-	}
+	c.setWriteDeadline(c.writeTimeout)
 	if err := c.writeCommand(cmd, args); err != nil {
 		return c.fatal(err)
 	}
 	return nil
 }
 
+// SendInline writes a command to the client's output buffer using the inline
+// command format. The parts of the command are separated by spaces and the
+// command is terminated by CRLF.
+func (c *conn) SendInline(parts ...string) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	if len(parts) == 0 {
+		return errors.New("redigo: inline command is empty")
+	}
+	for _, p := range parts {
+		if p == "" || strings.ContainsAny(p, " \t\r\n") {
+			return fmt.Errorf("redigo: inline command part %q is empty or contains whitespace", p)
+		}
+	}
+	c.mu.Lock()
+	c.pending += 1
+	c.mu.Unlock()
+	c.setWriteDeadline(c.writeTimeout)
+	c.bw.WriteString(strings.Join(parts, " "))
+	if _, err := c.bw.WriteString("\r\n"); err != nil {
+		return c.fatal(err)
+	}
+	return nil
+}
+
 func (c *conn) Flush() error {
-	if c.writeTimeout != 0 {
-		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	if err := c.Err(); err != nil {
+		return err
 	}
+	c.setWriteDeadline(c.writeTimeout)
 	if err := c.bw.Flush(); err != nil {
 		return c.fatal(err)
 	}
@@ -253,6 +835,9 @@ func (c *conn) Flush() error {
 }
 
 func (c *conn) Receive() (reply interface{}, err error) {
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
 	c.mu.Lock()
 	// There can be more receives than sends when using pub/sub. To allow
 	// normal use of the connection after unsubscribe from all channels, do not
@@ -261,9 +846,7 @@ func (c *conn) Receive() (reply interface{}, err error) {
 		c.pending -= 1
 	}
 	c.mu.Unlock()
-	if c.readTimeout != 0 {
-		c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
-	}
+	c.setReadDeadline(c.readTimeout)
 	if reply, err = c.readReply(); err != nil {
 		return nil, c.fatal(err)
 	}
@@ -273,20 +856,351 @@ func (c *conn) Receive() (reply interface{}, err error) {
 	return
 }
 
+// ReceiveWithTimeout acts like Receive, but uses timeout as the read timeout
+// for waiting on the reply. If the timeout expires before the server starts
+// sending a reply, then ReceiveWithTimeout returns the timeout error and the
+// connection remains usable.
+func (c *conn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	c.setReadDeadline(timeout)
+	if c.bulk == nil {
+		// Wait for the first byte of the reply. A timeout here does not
+		// consume any input.
+		if _, err := c.br.Peek(1); err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				return nil, err
+			}
+			return nil, c.fatal(err)
+		}
+	}
+	c.mu.Lock()
+	if c.pending > 0 {
+		c.pending -= 1
+	}
+	c.mu.Unlock()
+	reply, err := c.readReply()
+	if err != nil {
+		return nil, c.fatal(err)
+	}
+	if err, ok := reply.(Error); ok {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// ReceiveWithKind receives a single reply from the server and returns the
+// reply with the type byte of the reply.
+func (c *conn) ReceiveWithKind() (byte, interface{}, error) {
+	if err := c.Err(); err != nil {
+		return 0, nil, err
+	}
+	c.mu.Lock()
+	if c.pending > 0 {
+		c.pending -= 1
+	}
+	c.mu.Unlock()
+	c.setReadDeadline(c.readTimeout)
+	kind, reply, err := c.readReplyKind()
+	if err != nil {
+		return 0, nil, c.fatal(err)
+	}
+	if err, ok := reply.(Error); ok {
+		return kind, nil, err
+	}
+	return kind, reply, nil
+}
+
+// ReceiveReader receives a single bulk reply from the server and returns a
+// reader for the reply payload. The payload is read directly from the
+// connection's input buffer. The reader is valid until the next call to a
+// connection method that reads from the server. Unread payload is discarded
+// by the next read.
+func (c *conn) ReceiveReader() (io.Reader, error) {
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.pending > 0 {
+		c.pending -= 1
+	}
+	c.mu.Unlock()
+	c.setReadDeadline(c.readTimeout)
+	if err := c.discardBulk(); err != nil {
+		return nil, c.fatal(err)
+	}
+	line, err := c.readReplyLine()
+	if err != nil {
+		return nil, c.fatal(err)
+	}
+	if len(line) > 0 && line[0] == '$' {
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, c.fatal(err)
+		}
+		if n < 0 {
+			return nil, ErrNil
+		}
+		r := &bulkReader{c: c, n: n}
+		c.bulk = r
+		if n == 0 {
+			if err := r.finish(); err != nil {
+				return nil, c.fatal(err)
+			}
+		}
+		return r, nil
+	}
+	reply, err := c.parseReply(line)
+	if err != nil {
+		return nil, c.fatal(err)
+	}
+	switch reply := reply.(type) {
+	case nil:
+		// The RESP3 null and the RESP2 nil multi-bulk reply.
+		return nil, ErrNil
+	case Error:
+		return nil, reply
+	}
+	return nil, fmt.Errorf("redigo: unexpected type for ReceiveReader, got type %T", reply)
+}
+
+// discardBulk discards the unread payload of the reply returned from the
+// previous call to ReceiveReader.
+func (c *conn) discardBulk() error {
+	if c.bulk == nil {
+		return nil
+	}
+	_, err := io.Copy(ioutil.Discard, c.bulk)
+	return err
+}
+
+// bulkReader reads the payload of a bulk reply from the connection.
+type bulkReader struct {
+	c   *conn
+	n   int
+	err error
+}
+
+func (r *bulkReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.c.bulk != r {
+		return 0, io.EOF
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	r.c.setReadDeadline(r.c.readTimeout)
+	n, err := r.c.br.Read(p)
+	r.n -= n
+	if err != nil {
+		r.err = r.c.fatal(err)
+		return n, r.err
+	}
+	if r.n == 0 {
+		if err := r.finish(); err != nil {
+			r.err = r.c.fatal(err)
+			return n, r.err
+		}
+	}
+	return n, nil
+}
+
+// finish consumes the CRLF following the payload.
+func (r *bulkReader) finish() error {
+	r.c.bulk = nil
+	line, err := r.c.readLine()
+	if err != nil {
+		return err
+	}
+	if len(line) != 0 {
+		return errors.New("redigo: bad bulk format")
+	}
+	return nil
+}
+
+// CopyReply receives a single reply from the server and writes the wire
+// encoding of the reply to w. Error replies are copied to w and are not
+// returned as errors. The connection is not usable after an error writing to
+// w because the unread part of the reply is not discarded.
+func (c *conn) CopyReply(w io.Writer) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.pending > 0 {
+		c.pending -= 1
+	}
+	c.mu.Unlock()
+	c.setReadDeadline(c.readTimeout)
+	if err := c.discardBulk(); err != nil {
+		return c.fatal(err)
+	}
+	line, err := c.readReplyLine()
+	if err != nil {
+		return c.fatal(err)
+	}
+	if err := c.copyFrame(w, line); err != nil {
+		return c.fatal(err)
+	}
+	return nil
+}
+
+// copyFrame writes the reply starting with line and the remainder of the reply
+// to w.
+func (c *conn) copyFrame(w io.Writer, line []byte) error {
+	if len(line) == 0 {
+		return errors.New("redigo: short response line")
+	}
+	kind := line[0]
+	var n int
+	switch kind {
+	case '+', '-', ':', '_', ',', '#', '(':
+	case '$', '=', '*', '~', '>', '%':
+		var err error
+		n, err = strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("redigo: protocol error: unexpected response line %q (type byte %q), possible reply desync", truncateLine(line), kind)
+	}
+	// The line is overwritten by the next read from the buffered reader.
+	if _, err := w.Write(line); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "\r\n"); err != nil {
+		return err
+	}
+	if n < 0 {
+		return nil
+	}
+	switch kind {
+	case '$', '=':
+		if _, err := io.CopyN(w, c.br, int64(n)); err != nil {
+			return err
+		}
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if len(line) != 0 {
+			return errors.New("redigo: bad bulk format")
+		}
+		_, err = io.WriteString(w, "\r\n")
+		return err
+	case '%':
+		n *= 2
+		fallthrough
+	case '*', '~', '>':
+		for i := 0; i < n; i++ {
+			line, err := c.readLine()
+			if err != nil {
+				return err
+			}
+			if err := c.copyFrame(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (c *conn) Do(cmd string, args ...interface{}) (interface{}, error) {
-	if c.writeTimeout != 0 {
-		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	return c.DoWithTimeout(c.readTimeout, cmd, args...)
+}
+
+// DoWithTimeout acts like Do, but uses readTimeout as the read timeout for
+// the replies. The read deadline is cleared after the replies are received. An
+// error reading a reply, including a timeout, permanently breaks the
+// connection.
+func (c *conn) DoWithTimeout(readTimeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	return c.do(readTimeout, c.writeTimeout, cmd, args)
+}
+
+// DoContext acts like Do, but uses the deadline from ctx as the read and
+// write deadline for the command when the deadline is earlier than the
+// connection timeouts. If ctx is done before the reply is received, then
+// DoContext closes the network connection and returns ctx.Err(). The
+// connection is not usable after a cancellation.
+func (c *conn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
+	readTimeout, writeTimeout := c.readTimeout, c.writeTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+		if readTimeout == 0 || d < readTimeout {
+			readTimeout = d
+		}
+		if writeTimeout == 0 || d < writeTimeout {
+			writeTimeout = d
+		}
+	}
+
+	if ctx.Done() == nil || c.conn == nil {
+		return c.do(readTimeout, writeTimeout, cmd, args)
+	}
+
+	stop := make(chan struct{})
+	cancelled := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.conn.Close()
+			cancelled <- true
+		case <-stop:
+			cancelled <- false
+		}
+	}()
+
+	reply, err := c.do(readTimeout, writeTimeout, cmd, args)
+	close(stop)
+	if <-cancelled {
+		return nil, c.fatal(ctx.Err())
+	}
+	return reply, err
+}
+
+func (c *conn) do(readTimeout, writeTimeout time.Duration, cmd string, args []interface{}) (interface{}, error) {
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+
+	c.setWriteDeadline(writeTimeout)
+
 	if cmd != "" {
 		c.writeCommand(cmd, args)
 	}
 
-    log.Println("Buffer size: ", c.bw.Buffered())
-    log.Println("Buffer avail: ", c.bw.Availible())
+	return c.flushAndReceive(readTimeout, cmd != "")
+}
 
+// DoRaw writes a command encoded by EncodeCommand to the server and returns
+// the reply. Pending replies are received as with Do.
+func (c *conn) DoRaw(serialized []byte) (interface{}, error) {
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+
+	c.setWriteDeadline(c.writeTimeout)
+	c.bw.Write(serialized)
+
+	return c.flushAndReceive(c.readTimeout, true)
+}
+
+// flushAndReceive flushes the output buffer and receives the pending replies.
+// If hasCmd is true, then the replies include the reply to a command written
+// by the caller and the last reply is returned. Otherwise, all pending replies
+// are returned.
+func (c *conn) flushAndReceive(readTimeout time.Duration, hasCmd bool) (interface{}, error) {
 	if err := c.bw.Flush(); err != nil {
-        log.Println("Error writing command in Do", err.Error())
 		return nil, c.fatal(err)
 	}
 
@@ -295,15 +1209,12 @@ func (c *conn) Do(cmd string, args ...interface{}) (interface{}, error) {
 	c.pending = 0
 	c.mu.Unlock()
 
-	if c.readTimeout != 0 {
-		c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
-	}
+	c.setReadDeadline(readTimeout)
 
-	if cmd == "" {
+	if !hasCmd {
 		reply := make([]interface{}, pending)
 		for i := range reply {
 			if r, e := c.readReply(); e != nil {
-                log.Println("Error reading connection reply: ", e.Error())
 				return nil, c.fatal(e)
 			} else {
 				reply[i] = r
@@ -317,7 +1228,6 @@ func (c *conn) Do(cmd string, args ...interface{}) (interface{}, error) {
 	for i := 0; i <= pending; i++ {
 		var e error
 		if reply, e = c.readReply(); e != nil {
-            log.Println("Error reading connection reply: ", e.Error())
 			return nil, c.fatal(e)
 		}
 		if e, ok := reply.(Error); ok && err == nil {
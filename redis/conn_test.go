@@ -17,11 +17,24 @@ package redis_test
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"github.com/garyburd/redigo/redis"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/big"
 	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -50,6 +63,42 @@ var writeTests = []struct {
 		[]interface{}{"SET", nil, []byte("foo")},
 		"*3\r\n$3\r\nSET\r\n$0\r\n\r\n$3\r\nfoo\r\n",
 	},
+	{
+		[]interface{}{"SET", "foo", true},
+		"*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$1\r\n1\r\n",
+	},
+	{
+		[]interface{}{"SET", "foo", false},
+		"*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$1\r\n0\r\n",
+	},
+	{
+		[]interface{}{"ZADD", "foo", 3.14, "bar"},
+		"*4\r\n$4\r\nZADD\r\n$3\r\nfoo\r\n$4\r\n3.14\r\n$3\r\nbar\r\n",
+	},
+	{
+		[]interface{}{"ZADD", "foo", float32(0.1), "bar"},
+		"*4\r\n$4\r\nZADD\r\n$3\r\nfoo\r\n$3\r\n0.1\r\n$3\r\nbar\r\n",
+	},
+	{
+		[]interface{}{"SET", "foo", time.Unix(1339518083, 500)},
+		"*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$10\r\n1339518083\r\n",
+	},
+	{
+		[]interface{}{"EXPIRE", "foo", 90 * time.Second},
+		"*3\r\n$6\r\nEXPIRE\r\n$3\r\nfoo\r\n$2\r\n90\r\n",
+	},
+	{
+		[]interface{}{"EXPIRE", "foo", 90*time.Second + 500*time.Millisecond},
+		"*3\r\n$6\r\nEXPIRE\r\n$3\r\nfoo\r\n$2\r\n91\r\n",
+	},
+	{
+		[]interface{}{"EXPIRE", "foo", 500 * time.Millisecond},
+		"*3\r\n$6\r\nEXPIRE\r\n$3\r\nfoo\r\n$1\r\n1\r\n",
+	},
+	{
+		[]interface{}{"SET", "foo", 1e21},
+		"*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$5\r\n1e+21\r\n",
+	},
 }
 
 func TestWrite(t *testing.T) {
@@ -70,6 +119,65 @@ func TestWrite(t *testing.T) {
 	}
 }
 
+func TestAppendCommand(t *testing.T) {
+	var buf []byte
+	for _, tt := range writeTests {
+		var err error
+		buf, err = redis.AppendCommand(buf[:0], tt.args[0].(string), tt.args[1:]...)
+		if err != nil {
+			t.Errorf("AppendCommand(%v) returned %v", tt.args, err)
+			continue
+		}
+		if string(buf) != tt.expected {
+			t.Errorf("AppendCommand(%v) = %q, want %q", tt.args, buf, tt.expected)
+		}
+		if encoded := redis.EncodeCommand(tt.args[0].(string), tt.args[1:]...); string(encoded) != tt.expected {
+			t.Errorf("EncodeCommand(%v) = %q, want %q", tt.args, encoded, tt.expected)
+		}
+		if p, err := redis.Command(tt.args[0].(string), tt.args[1:]...); err != nil || string(p) != tt.expected {
+			t.Errorf("Command(%v) = %q, %v, want %q, nil", tt.args, p, err, tt.expected)
+		}
+	}
+	if _, err := redis.Command(""); err == nil {
+		t.Errorf("Command with empty command name did not return error")
+	}
+
+	buf, err := redis.AppendCommand([]byte("prefix"), "PING")
+	if err != nil || string(buf) != "prefix*1\r\n$4\r\nPING\r\n" {
+		t.Errorf("AppendCommand returned %q, %v", buf, err)
+	}
+	if _, err := redis.AppendCommand(nil, ""); err == nil {
+		t.Errorf("AppendCommand with empty command name did not return error")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("EncodeCommand with empty command name did not panic")
+			}
+		}()
+		redis.EncodeCommand("")
+	}()
+}
+
+func TestSendFlush(t *testing.T) {
+	var buf bytes.Buffer
+	rw := bufio.ReadWriter{Writer: bufio.NewWriter(&buf)}
+	c := redis.NewConnBufio(rw)
+	c.Send("SET", "foo", "bar")
+	c.Send("GET", "foo")
+	if buf.Len() != 0 {
+		t.Fatalf("Send wrote %q before Flush", buf.String())
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush returned error %v", err)
+	}
+	expected := "*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$3\r\nbar\r\n*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n"
+	if actual := buf.String(); actual != expected {
+		t.Errorf("Flush wrote %q, want %q", actual, expected)
+	}
+}
+
 var errorSentinel = &struct{}{}
 
 var readTests = []struct {
@@ -88,6 +196,10 @@ var readTests = []struct {
 		"$6\r\nfoobar\r\n",
 		[]byte("foobar"),
 	},
+	{
+		"$2\r\nOK\r\n",
+		[]byte("OK"),
+	},
 	{
 		"$-1\r\n",
 		nil,
@@ -112,6 +224,87 @@ var readTests = []struct {
 		"*3\r\n$3\r\nfoo\r\n$-1\r\n$3\r\nbar\r\n",
 		[]interface{}{[]byte("foo"), nil, []byte("bar")},
 	},
+	{
+		"_\r\n",
+		nil,
+	},
+	{
+		"%2\r\n+k1\r\n:1\r\n$2\r\nk2\r\n_\r\n",
+		[]interface{}{"k1", int64(1), []byte("k2"), nil},
+	},
+	{
+		"%1\r\n$1\r\nk\r\n%1\r\n$1\r\nn\r\n:2\r\n",
+		[]interface{}{[]byte("k"), []interface{}{[]byte("n"), int64(2)}},
+	},
+	{
+		"%0\r\n",
+		[]interface{}{},
+	},
+	{
+		"~2\r\n$1\r\na\r\n$1\r\nb\r\n",
+		[]interface{}{[]byte("a"), []byte("b")},
+	},
+	{
+		",3.14\r\n",
+		3.14,
+	},
+	{
+		",-inf\r\n",
+		math.Inf(-1),
+	},
+	{
+		"#t\r\n",
+		true,
+	},
+	{
+		"#f\r\n",
+		false,
+	},
+	{
+		"#x\r\n",
+		errorSentinel,
+	},
+	{
+		",abc\r\n",
+		errorSentinel,
+	},
+	{
+		"*3\r\n+OK\r\n$2\r\nOK\r\n:1\r\n",
+		[]interface{}{"OK", []byte("OK"), int64(1)},
+	},
+	{
+		"(3492890328409238509324850943850943825024385\r\n",
+		bigInt("3492890328409238509324850943850943825024385"),
+	},
+	{
+		"(-12\r\n",
+		big.NewInt(-12),
+	},
+	{
+		"(12a\r\n",
+		errorSentinel,
+	},
+	{
+		"=15\r\ntxt:Some string\r\n",
+		[]byte("Some string"),
+	},
+	{
+		"=4\r\nmkd:\r\n",
+		[]byte{},
+	},
+	{
+		"=3\r\ntxt\r\n",
+		errorSentinel,
+	},
+	{
+		"=-1\r\n",
+		errorSentinel,
+	},
+}
+
+func bigInt(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
 }
 
 func TestRead(t *testing.T) {
@@ -138,6 +331,321 @@ func TestRead(t *testing.T) {
 	}
 }
 
+func TestSendInline(t *testing.T) {
+	var buf bytes.Buffer
+	rw := bufio.ReadWriter{
+		Reader: bufio.NewReader(strings.NewReader("+PONG\r\n$3\r\nbar\r\n")),
+		Writer: bufio.NewWriter(&buf),
+	}
+	c := redis.NewConnBufio(rw)
+
+	if err := redis.SendInline(c, "PING"); err != nil {
+		t.Fatalf("SendInline returned %v", err)
+	}
+	if err := redis.SendInline(c, "GET", "foo"); err != nil {
+		t.Fatalf("SendInline returned %v", err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush returned %v", err)
+	}
+	if expected := "PING\r\nGET foo\r\n"; buf.String() != expected {
+		t.Errorf("SendInline wrote %q, want %q", buf.String(), expected)
+	}
+	if v, err := c.Receive(); err != nil || v != "PONG" {
+		t.Errorf("Receive returned %v, %v, want PONG, nil", v, err)
+	}
+	if v, err := redis.String(c.Receive()); err != nil || v != "bar" {
+		t.Errorf("Receive returned %v, %v, want bar, nil", v, err)
+	}
+
+	for _, parts := range [][]string{nil, {"SET", "foo", "hello world"}, {"GET", ""}, {"GET", "a\r\n"}} {
+		if err := redis.SendInline(c, parts...); err == nil {
+			t.Errorf("SendInline(%q) did not return error", parts)
+		}
+	}
+	if c.Err() != nil {
+		t.Errorf("Conn has Err()=%v, expect nil", c.Err())
+	}
+}
+
+func TestReceiveWithKind(t *testing.T) {
+	rw := bufio.ReadWriter{
+		Reader: bufio.NewReader(strings.NewReader(
+			"+OK\r\n-ERR bad\r\n:1\r\n$3\r\nfoo\r\n*1\r\n$3\r\nbar\r\n_\r\n%1\r\n+k\r\n+v\r\n,1.5\r\n#t\r\n")),
+		Writer: bufio.NewWriter(nil),
+	}
+	c := redis.NewConnBufio(rw)
+	expected := []struct {
+		kind  byte
+		reply interface{}
+		err   error
+	}{
+		{'+', "OK", nil},
+		{'-', nil, redis.Error("ERR bad")},
+		{':', int64(1), nil},
+		{'$', []byte("foo"), nil},
+		{'*', []interface{}{[]byte("bar")}, nil},
+		{'_', nil, nil},
+		{'%', []interface{}{"k", "v"}, nil},
+		{',', 1.5, nil},
+		{'#', true, nil},
+	}
+	for _, e := range expected {
+		kind, reply, err := redis.ReceiveWithKind(c)
+		if kind != e.kind || !reflect.DeepEqual(reply, e.reply) || err != e.err {
+			t.Errorf("ReceiveWithKind returned %q, %v, %v, want %q, %v, %v", kind, reply, err, e.kind, e.reply, e.err)
+		}
+	}
+}
+
+func TestReadErrorReply(t *testing.T) {
+	rw := bufio.ReadWriter{
+		Reader: bufio.NewReader(strings.NewReader("-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")),
+		Writer: bufio.NewWriter(nil),
+	}
+	c := redis.NewConnBufio(rw)
+	_, err := c.Receive()
+	expected := redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+	if e, ok := err.(redis.Error); !ok || e != expected {
+		t.Errorf("Receive returned error %#v, want %#v", err, expected)
+	}
+	if c.Err() != nil {
+		t.Errorf("Conn has Err()=%v, expect nil", c.Err())
+	}
+}
+
+func TestProtocolError(t *testing.T) {
+	rw := bufio.ReadWriter{
+		Reader: bufio.NewReader(strings.NewReader("+OK\r\nfoobar\r\n")),
+		Writer: bufio.NewWriter(nil),
+	}
+	c := redis.NewConnBufio(rw)
+	if _, err := c.Receive(); err != nil {
+		t.Fatalf("Receive returned error %v", err)
+	}
+	_, err := c.Receive()
+	if err == nil {
+		t.Fatalf("Receive did not return error for desynchronized reply")
+	}
+	if s := err.Error(); !strings.Contains(s, "protocol error") || !strings.Contains(s, `'f'`) {
+		t.Errorf("Receive returned error %q, want protocol error with type byte 'f'", s)
+	}
+	if c.Err() != err {
+		t.Errorf("Err() = %v, want %v", c.Err(), err)
+	}
+}
+
+func TestErrLatched(t *testing.T) {
+	var buf bytes.Buffer
+	rw := bufio.ReadWriter{
+		Reader: bufio.NewReader(strings.NewReader("@OK\r\n+OK\r\n")),
+		Writer: bufio.NewWriter(&buf),
+	}
+	c := redis.NewConnBufio(rw)
+	if _, err := c.Receive(); err == nil {
+		t.Fatalf("Receive did not return error")
+	}
+	err := c.Err()
+	if err == nil {
+		t.Fatalf("Err() returned nil after protocol error")
+	}
+	if _, e := c.Receive(); e != err {
+		t.Errorf("Receive returned %v, want %v", e, err)
+	}
+	if e := c.Send("PING"); e != err {
+		t.Errorf("Send returned %v, want %v", e, err)
+	}
+	if _, e := c.Do("PING"); e != err {
+		t.Errorf("Do returned %v, want %v", e, err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("connection wrote %q after error", buf.String())
+	}
+}
+
+func TestReceiveReader(t *testing.T) {
+	rw := bufio.ReadWriter{
+		Reader: bufio.NewReader(strings.NewReader(
+			"$11\r\nhello world\r\n" +
+				"$11\r\nhello world\r\n" +
+				"$0\r\n\r\n" +
+				"$-1\r\n" +
+				"-ERR bad\r\n" +
+				":1\r\n" +
+				"+OK\r\n")),
+		Writer: bufio.NewWriter(nil),
+	}
+	c := redis.NewConnBufio(rw)
+
+	r, err := redis.ReceiveReader(c)
+	if err != nil {
+		t.Fatalf("ReceiveReader returned error %v", err)
+	}
+	p, err := ioutil.ReadAll(r)
+	if err != nil || string(p) != "hello world" {
+		t.Fatalf("ReadAll returned %q, %v, want %q, nil", p, err, "hello world")
+	}
+
+	// Partially read payload is discarded by the next read.
+	r, err = redis.ReceiveReader(c)
+	if err != nil {
+		t.Fatalf("ReceiveReader returned error %v", err)
+	}
+	p = make([]byte, 5)
+	if _, err := io.ReadFull(r, p); err != nil || string(p) != "hello" {
+		t.Fatalf("ReadFull returned %q, %v, want %q, nil", p, err, "hello")
+	}
+
+	r, err = redis.ReceiveReader(c)
+	if err != nil {
+		t.Fatalf("ReceiveReader returned error %v", err)
+	}
+	if p, err := ioutil.ReadAll(r); err != nil || len(p) != 0 {
+		t.Fatalf("ReadAll returned %q, %v, want empty payload", p, err)
+	}
+
+	if _, err := redis.ReceiveReader(c); err != redis.ErrNil {
+		t.Errorf("ReceiveReader returned error %v, want %v", err, redis.ErrNil)
+	}
+	if _, err := redis.ReceiveReader(c); err != redis.Error("ERR bad") {
+		t.Errorf("ReceiveReader returned error %v, want ERR bad", err)
+	}
+	if _, err := redis.ReceiveReader(c); err == nil {
+		t.Errorf("ReceiveReader did not return error for integer reply")
+	}
+	if c.Err() != nil {
+		t.Fatalf("Conn has Err()=%v, expect nil", c.Err())
+	}
+	if v, err := c.Receive(); err != nil || v != "OK" {
+		t.Errorf("Receive returned %v, %v, want OK, nil", v, err)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "MONITOR" {
+			return "+OK\r\n" +
+				"+1339518083.107412 [0 127.0.0.1:60866] \"keys\" \"*\"\r\n" +
+				"+1339518087.877697 [0 127.0.0.1:60866] \"dbsize\"\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned error %v", err)
+	}
+	defer c.Close()
+
+	c.Send("MONITOR")
+	c.Flush()
+	expected := []string{
+		"OK",
+		`1339518083.107412 [0 127.0.0.1:60866] "keys" "*"`,
+		`1339518087.877697 [0 127.0.0.1:60866] "dbsize"`,
+	}
+	for _, want := range expected {
+		line, err := redis.String(c.Receive())
+		if err != nil {
+			t.Fatalf("Receive returned error %v", err)
+		}
+		if line != want {
+			t.Errorf("Receive returned %q, want %q", line, want)
+		}
+	}
+}
+
+func TestReceiveTo(t *testing.T) {
+	rw := bufio.ReadWriter{
+		Reader: bufio.NewReader(strings.NewReader("$11\r\nhello world\r\n:1\r\n$3\r\nfoo\r\n+OK\r\n")),
+		Writer: bufio.NewWriter(nil),
+	}
+	c := redis.NewConnBufio(rw)
+
+	var buf bytes.Buffer
+	n, err := redis.ReceiveTo(c, &buf)
+	if err != nil || n != 11 || buf.String() != "hello world" {
+		t.Fatalf("ReceiveTo returned %d, %v with %q, want 11, nil with %q", n, err, buf.String(), "hello world")
+	}
+	if _, err := redis.ReceiveTo(c, &buf); err == nil {
+		t.Errorf("ReceiveTo did not return error for integer reply")
+	}
+
+	// A failed write leaves the connection usable.
+	if _, err := redis.ReceiveTo(c, errorWriter{}); err == nil {
+		t.Errorf("ReceiveTo did not return write error")
+	}
+	if v, err := c.Receive(); err != nil || v != "OK" {
+		t.Errorf("Receive returned %v, %v, want OK, nil", v, err)
+	}
+}
+
+func TestReceiveBulkNotShared(t *testing.T) {
+	rw := bufio.ReadWriter{
+		Reader: bufio.NewReaderSize(strings.NewReader("*1\r\n$5\r\nhello\r\n$5\r\nworld\r\n"), 16),
+		Writer: bufio.NewWriter(nil),
+	}
+	c := redis.NewConnBufio(rw)
+
+	values, err := redis.Values(c.Receive())
+	if err != nil {
+		t.Fatalf("Receive returned %v", err)
+	}
+	first := values[0].([]byte)
+	second, err := redis.Bytes(c.Receive())
+	if err != nil {
+		t.Fatalf("Receive returned %v", err)
+	}
+	if string(first) != "hello" {
+		t.Errorf("first reply = %q after second read, want %q", first, "hello")
+	}
+	first[0] = 'j'
+	if string(second) != "world" {
+		t.Errorf("second reply = %q after modifying first, want %q", second, "world")
+	}
+}
+
+func TestCopyReply(t *testing.T) {
+	replies := []string{
+		"+OK\r\n",
+		"-ERR fail\r\n",
+		":42\r\n",
+		"$5\r\nhello\r\n",
+		"$-1\r\n",
+		"$0\r\n\r\n",
+		"*-1\r\n",
+		"*3\r\n:1\r\n$3\r\nfoo\r\n*1\r\n+bar\r\n",
+		"%1\r\n+key\r\n,1.5\r\n",
+		"~2\r\n#t\r\n_\r\n",
+		"=15\r\ntxt:Some string\r\n",
+		"(3492890328409238509324850943850943825024385\r\n",
+	}
+	rw := bufio.ReadWriter{
+		Reader: bufio.NewReader(strings.NewReader(strings.Join(replies, "") + "@\r\n")),
+		Writer: bufio.NewWriter(nil),
+	}
+	c := redis.NewConnBufio(rw)
+
+	for _, reply := range replies {
+		var buf bytes.Buffer
+		if err := redis.CopyReply(c, &buf); err != nil {
+			t.Errorf("CopyReply for %q returned %v", reply, err)
+			continue
+		}
+		if buf.String() != reply {
+			t.Errorf("CopyReply copied %q, want %q", buf.String(), reply)
+		}
+	}
+	if err := redis.CopyReply(c, ioutil.Discard); err == nil {
+		t.Errorf("CopyReply did not return error for invalid reply")
+	}
+}
+
+type errorWriter struct{}
+
+func (errorWriter) Write(p []byte) (int, error) { return 0, errors.New("write error") }
+
 type testConn struct {
 	redis.Conn
 }
@@ -371,10 +879,971 @@ func TestReadDeadline(t *testing.T) {
 	if err == nil {
 		t.Fatalf("Receive did not return error.")
 	}
+
+	c3, err := redis.Dial(l.Addr().Network(), l.Addr().String())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c3.Close()
+
+	c4, err := redis.Dial(l.Addr().Network(), l.Addr().String(), redis.DialReadTimeout(time.Millisecond))
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c4.Close()
+
+	_, err = c4.Do("PING")
+	if err == nil {
+		t.Fatalf("Do with DialReadTimeout did not return error.")
+	}
+
+	_, err = redis.DoWithTimeout(c3, time.Millisecond, "PING")
+	if err == nil {
+		t.Fatalf("DoWithTimeout did not return error.")
+	}
+	if c3.Err() == nil {
+		t.Fatalf("Conn has nil Err() after timeout.")
+	}
+
+	nc, err := net.Dial(l.Addr().Network(), l.Addr().String())
+	if err != nil {
+		t.Fatalf("net.Dial returned %v", err)
+	}
+	c5 := redis.NewConn(nc, time.Millisecond, 0)
+	defer c5.Close()
+
+	_, err = c5.Do("PING")
+	if err == nil {
+		t.Fatalf("Do with NewConn read timeout did not return error.")
+	}
+}
+
+func TestDoOnce(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		return "$3\r\nbar\r\n"
+	})
+	defer s.close()
+
+	v, err := redis.String(redis.DoOnce("tcp", s.addr(), "GET", "foo"))
+	if err != nil || v != "bar" {
+		t.Fatalf("DoOnce returned %q, %v, want bar, nil", v, err)
+	}
+	if expected := [][]string{{"GET", "foo"}}; !reflect.DeepEqual(s.received(), expected) {
+		t.Errorf("commands = %v, want %v", s.received(), expected)
+	}
+
+	s.close()
+	if _, err := redis.DoOnce("tcp", s.addr(), "GET", "foo"); err == nil {
+		t.Errorf("DoOnce did not return error for closed server")
+	}
+}
+
+func TestPing(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		return "+PONG\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	if err := redis.Ping(c); err != nil {
+		t.Fatalf("Ping returned %v", err)
+	}
+	if err := redis.Ping(redis.NewLoggingConn(c, log.New(ioutil.Discard, "", 0), "")); err != nil {
+		t.Fatalf("Ping on logging connection returned %v", err)
+	}
+
+	bad := newFakeServer(t, nil)
+	defer bad.close()
+
+	c, err = redis.Dial("tcp", bad.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	if err := redis.Ping(c); err == nil {
+		t.Fatalf("Ping did not return error for OK reply")
+	}
+	if c.Err() == nil {
+		t.Errorf("Conn has nil Err() after failed Ping")
+	}
+}
+
+func TestNewConn(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.close()
+
+	nc, err := net.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("net.Dial returned %v", err)
+	}
+	c := redis.NewConn(nc, time.Second, time.Second)
+	defer c.Close()
+
+	if v, err := c.Do("PING"); err != nil || v != "OK" {
+		t.Fatalf("Do(PING) returned %v, %v, want OK, nil", v, err)
+	}
+	if actual, _ := redis.NetConn(c); actual != nc {
+		t.Errorf("NetConn returned %v, want %v", actual, nc)
+	}
+}
+
+func TestDeadlineReset(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := redis.DoContext(c, ctx, "PING"); err != nil {
+		t.Fatalf("DoContext returned %v", err)
+	}
+	if _, err := redis.DoWithTimeout(c, 50*time.Millisecond, "PING"); err != nil {
+		t.Fatalf("DoWithTimeout returned %v", err)
+	}
+
+	// The deadlines set by the calls above must not apply to later calls.
+	time.Sleep(100 * time.Millisecond)
+	if err := c.Send("PING"); err != nil {
+		t.Fatalf("Send returned %v", err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush returned %v", err)
+	}
+	if _, err := c.Receive(); err != nil {
+		t.Fatalf("Receive returned %v", err)
+	}
+	if _, err := c.Do("PING"); err != nil {
+		t.Fatalf("Do returned %v", err)
+	}
+}
+
+func TestDoContext(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen returned %v", err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				time.Sleep(time.Second)
+				c.Write([]byte("+OK\r\n"))
+				c.Close()
+			}()
+		}
+	}()
+
+	c1, err := redis.Dial(l.Addr().Network(), l.Addr().String())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c1.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := redis.DoContext(c1, ctx, "PING"); err != context.Canceled {
+		t.Fatalf("DoContext returned %v, want %v", err, context.Canceled)
+	}
+	if c1.Err() == nil {
+		t.Fatalf("Conn has nil Err() after cancel.")
+	}
+
+	c2, err := redis.Dial(l.Addr().Network(), l.Addr().String())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c2.Close()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := redis.DoContext(c2, ctx, "PING"); err == nil {
+		t.Fatalf("DoContext did not return error after deadline.")
+	}
+	if c2.Err() == nil {
+		t.Fatalf("Conn has nil Err() after deadline.")
+	}
+
+	// A cancelled pooled connection is not returned to the pool.
+	p := &redis.Pool{
+		MaxIdle: 1,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial(l.Addr().Network(), l.Addr().String())
+		},
+	}
+	defer p.Close()
+	c3 := p.Get()
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := redis.DoContext(c3, ctx, "PING"); err != context.Canceled {
+		t.Fatalf("DoContext on pooled connection returned %v, want %v", err, context.Canceled)
+	}
+	c3.Close()
+	if stats := p.Stats(); stats.IdleCount != 0 || stats.ActiveCount != 0 {
+		t.Errorf("pool stats after cancel = %+v, want no idle or active connections", stats)
+	}
+}
+
+func TestDialTLSHandshakeError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen returned %v", err)
+	}
+	defer l.Close()
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		c.Write([]byte("+OK\r\n"))
+		c.Close()
+	}()
+
+	_, err = redis.DialTLS(l.Addr().Network(), l.Addr().String(), nil)
+	if err == nil {
+		t.Fatalf("DialTLS did not return error for non-TLS server.")
+	}
+}
+
+// tlsConfigs returns a server configuration with the httptest certificate and
+// a client configuration that trusts the certificate.
+func tlsConfigs() (*tls.Config, *tls.Config) {
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	return &tls.Config{Certificates: srv.TLS.Certificates}, &tls.Config{RootCAs: roots}
+}
+
+func TestDialTLS(t *testing.T) {
+	serverConfig, clientConfig := tlsConfigs()
+	l, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatalf("tls.Listen returned %v", err)
+	}
+	s := newFakeServerListener(l, func(args []string) string {
+		if args[0] == "PING" {
+			return "+PONG\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.DialTLS("tcp", s.addr(), clientConfig, redis.DialConnectTimeout(5*time.Second))
+	if err != nil {
+		t.Fatalf("DialTLS returned %v", err)
+	}
+	defer c.Close()
+	if v, err := redis.String(c.Do("PING")); err != nil || v != "PONG" {
+		t.Errorf("Do(PING) returned %q, %v, want PONG, nil", v, err)
+	}
+}
+
+func TestDialTLSConnectTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen returned %v", err)
+	}
+	defer l.Close()
+
+	// The server accepts the connection and never completes the handshake.
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		ioutil.ReadAll(c)
+	}()
+
+	start := time.Now()
+	_, err = redis.DialTLS("tcp", l.Addr().String(), nil, redis.DialConnectTimeout(50*time.Millisecond))
+	if err == nil {
+		t.Fatal("DialTLS returned nil error, want handshake timeout")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("DialTLS returned after %v, want connect timeout", d)
+	}
+}
+
+// fakeServer is a minimal Redis server for testing connection setup. The
+// server replies to each command with the reply returned by the handle
+// function and records the commands in the order received.
+type fakeServer struct {
+	l        net.Listener
+	mu       sync.Mutex
+	commands [][]string
+	handle   func(args []string) string
+}
+
+func newFakeServer(t *testing.T, handle func(args []string) string) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen returned %v", err)
+	}
+	return newFakeServerListener(l, handle)
+}
+
+func newFakeServerListener(l net.Listener, handle func(args []string) string) *fakeServer {
+	if handle == nil {
+		handle = func([]string) string { return "+OK\r\n" }
+	}
+	s := &fakeServer{l: l, handle: handle}
+	go s.serve()
+	return s
+}
+
+func (s *fakeServer) serve() {
+	for {
+		c, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		go s.serveConn(c)
+	}
+}
+
+func (s *fakeServer) serveConn(c net.Conn) {
+	defer c.Close()
+	br := bufio.NewReader(c)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			if _, err := br.ReadString('\n'); err != nil {
+				return
+			}
+			arg, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			args[i] = strings.TrimSuffix(arg, "\r\n")
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, args)
+		s.mu.Unlock()
+		if _, err := c.Write([]byte(s.handle(args))); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) addr() string { return s.l.Addr().String() }
+
+func (s *fakeServer) close() { s.l.Close() }
+
+func (s *fakeServer) received() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands
+}
+
+func TestDialURL(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.close()
+
+	c, err := redis.DialURL("redis://:secret@" + s.addr() + "/3")
+	if err != nil {
+		t.Fatalf("DialURL returned %v", err)
+	}
+	c.Close()
+
+	expected := [][]string{{"AUTH", "secret"}, {"SELECT", "3"}}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+}
+
+func TestDialOptions(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr(), redis.DialPassword("secret"), redis.DialDatabase(9))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	c.Close()
+
+	expected := [][]string{{"AUTH", "secret"}, {"SELECT", "9"}}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c, err := redis.DialContext(ctx, "tcp", s.addr(), redis.DialDatabase(2))
+	if err != nil {
+		t.Fatalf("DialContext returned %v", err)
+	}
+	c.Close()
+
+	expected := [][]string{{"SELECT", "2"}}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := redis.DialContext(ctx, "tcp", s.addr()); err == nil {
+		t.Errorf("DialContext with cancelled context did not return error")
+	}
+}
+
+func TestDialConnectTimeout(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr(), redis.DialConnectTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	c.Close()
+
+	if _, err := redis.Dial("tcp", s.addr(), redis.DialConnectTimeout(time.Nanosecond)); err == nil {
+		t.Errorf("Dial with expired connect timeout did not return error")
+	}
+}
+
+func TestNetConn(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	for _, c := range []redis.Conn{c, redis.NewLoggingConn(c, log.New(ioutil.Discard, "", 0), "")} {
+		nc, err := redis.NetConn(c)
+		if err != nil {
+			t.Fatalf("NetConn returned %v", err)
+		}
+		tc, ok := nc.(*net.TCPConn)
+		if !ok {
+			t.Fatalf("NetConn returned %T, want *net.TCPConn", nc)
+		}
+		if err := tc.SetNoDelay(false); err != nil {
+			t.Fatalf("SetNoDelay returned %v", err)
+		}
+	}
+
+	if _, err := c.Do("PING"); err != nil {
+		t.Fatalf("Do(PING) returned %v", err)
+	}
+
+	if _, err := redis.NetConn(redis.NewConnBufio(bufio.ReadWriter{})); err == nil {
+		t.Errorf("NetConn did not return error for connection without network connection")
+	}
+}
+
+func TestDialBufferSize(t *testing.T) {
+	value := strings.Repeat("x", 100)
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "GET":
+			return "$100\r\n" + value + "\r\n"
+		case "FAIL":
+			return "-ERR " + value + "\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr(), redis.DialReadBufferSize(16), redis.DialWriteBufferSize(16))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Do("SET", "foo", value); err != nil {
+		t.Fatalf("Do(SET) returned %v", err)
+	}
+	if actual := s.received(); len(actual) != 1 || actual[0][2] != value {
+		t.Errorf("server received %v, want SET with %d byte value", actual, len(value))
+	}
+	if v, err := redis.String(c.Do("GET", "foo")); err != nil || v != value {
+		t.Fatalf("Do(GET) returned %q, %v, want %q, nil", v, err, value)
+	}
+	if _, err := c.Do("FAIL"); err == nil || c.Err() == nil {
+		t.Errorf("Do(FAIL) returned %v, want long response line error", err)
+	}
+}
+
+func TestDialUseRESP3(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "HELLO":
+			return "%3\r\n$6\r\nserver\r\n$5\r\nredis\r\n$5\r\nproto\r\n:3\r\n" +
+				"$7\r\nmodules\r\n*1\r\n%1\r\n$4\r\nname\r\n$3\r\nfoo\r\n"
+		case "GET":
+			return "_\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr(), redis.DialPassword("secret"), redis.DialUseRESP3(), redis.DialDatabase(1))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	if _, err := redis.String(c.Do("GET", "missing")); err != redis.ErrNil {
+		t.Errorf("Do(GET) returned %v, want %v", err, redis.ErrNil)
+	}
+
+	expected := [][]string{{"AUTH", "secret"}, {"HELLO", "3"}, {"SELECT", "1"}, {"GET", "missing"}}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+
+	old := newFakeServer(t, func(args []string) string {
+		if args[0] == "HELLO" {
+			return "-ERR unknown command 'HELLO'\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer old.close()
+
+	_, err = redis.Dial("tcp", old.addr(), redis.DialUseRESP3())
+	if err == nil || !strings.Contains(err.Error(), "RESP3") {
+		t.Errorf("Dial returned %v, want RESP3 not supported error", err)
+	}
+}
+
+func TestRESP3Map(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "HELLO":
+			return "%1\r\n$5\r\nproto\r\n:3\r\n"
+		case "CONFIG":
+			return "%2\r\n$9\r\nmaxmemory\r\n$1\r\n0\r\n$10\r\nmaxclients\r\n$5\r\n10000\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr(), redis.DialUseRESP3())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	m, err := redis.IntMap(c.Do("CONFIG", "GET", "max*"))
+	if err != nil {
+		t.Fatalf("IntMap returned %v", err)
+	}
+	if expected := map[string]int{"maxmemory": 0, "maxclients": 10000}; !reflect.DeepEqual(m, expected) {
+		t.Errorf("IntMap returned %v, want %v", m, expected)
+	}
+}
+
+func TestRESP3Set(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "HELLO":
+			return "%1\r\n$5\r\nproto\r\n:3\r\n"
+		case "SMEMBERS":
+			switch args[1] {
+			case "empty":
+				return "~0\r\n"
+			case "bad":
+				return "~-1\r\n"
+			}
+			return "~2\r\n$1\r\na\r\n$1\r\nb\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr(), redis.DialUseRESP3())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	members, err := redis.Strings(c.Do("SMEMBERS", "set"))
+	if expected := []string{"a", "b"}; err != nil || !reflect.DeepEqual(members, expected) {
+		t.Errorf("Strings(SMEMBERS set) = %v, %v, want %v, nil", members, err, expected)
+	}
+	members, err = redis.Strings(c.Do("SMEMBERS", "empty"))
+	if err != nil || len(members) != 0 {
+		t.Errorf("Strings(SMEMBERS empty) = %v, %v, want [], nil", members, err)
+	}
+	// RESP3 does not have a null set.
+	if _, err := c.Do("SMEMBERS", "bad"); err == nil {
+		t.Error("Do(SMEMBERS bad) did not return error")
+	}
+}
+
+func TestRESP3Null(t *testing.T) {
+	// The RESP3 null and the RESP2 nil bulk and multi-bulk replies are
+	// indistinguishable to applications.
+	for _, null := range []string{"$-1\r\n", "*-1\r\n", "_\r\n"} {
+		rw := bufio.ReadWriter{
+			Reader: bufio.NewReader(strings.NewReader(strings.Repeat(null, 5) + "*2\r\n$1\r\na\r\n" + null)),
+			Writer: bufio.NewWriter(nil),
+		}
+		c := redis.NewConnBufio(rw)
+		if v, err := c.Receive(); v != nil || err != nil {
+			t.Errorf("Receive(%q) = %v, %v, want nil, nil", null, v, err)
+		}
+		if _, err := redis.String(c.Receive()); err != redis.ErrNil {
+			t.Errorf("String(%q) returned %v, want %v", null, err, redis.ErrNil)
+		}
+		if _, err := redis.Int(c.Receive()); err != redis.ErrNil {
+			t.Errorf("Int(%q) returned %v, want %v", null, err, redis.ErrNil)
+		}
+		if _, err := redis.Values(c.Receive()); err != redis.ErrNil {
+			t.Errorf("Values(%q) returned %v, want %v", null, err, redis.ErrNil)
+		}
+		if _, err := redis.ReceiveReader(c); err != redis.ErrNil {
+			t.Errorf("ReceiveReader(%q) returned %v, want %v", null, err, redis.ErrNil)
+		}
+		v, err := redis.ByteSlices(c.Receive())
+		if expected := [][]byte{[]byte("a"), nil}; err != nil || !reflect.DeepEqual(v, expected) {
+			t.Errorf("ByteSlices with %q element = %q, %v, want %q, nil", null, v, err, expected)
+		}
+	}
+}
+
+func TestOnPush(t *testing.T) {
+	const push = ">2\r\n$10\r\ninvalidate\r\n*1\r\n$3\r\nfoo\r\n"
+	rw := bufio.ReadWriter{
+		Reader: bufio.NewReader(strings.NewReader(
+			push + "+OK\r\n" +
+				"*2\r\n$1\r\na\r\n" + "$1\r\nb\r\n" +
+				push + push + "$3\r\nbar\r\n" +
+				push)),
+		Writer: bufio.NewWriter(nil),
+	}
+	c := redis.NewConnBufio(rw)
+
+	var pushes [][]interface{}
+	if err := redis.OnPush(c, func(p []interface{}) { pushes = append(pushes, p) }); err != nil {
+		t.Fatalf("OnPush returned %v", err)
+	}
+
+	if v, err := c.Receive(); err != nil || v != "OK" {
+		t.Errorf("Receive returned %v, %v, want OK, nil", v, err)
+	}
+	if v, err := redis.Strings(c.Receive()); err != nil || !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Errorf("Receive returned %v, %v, want [a b], nil", v, err)
+	}
+	r, err := redis.ReceiveReader(c)
+	if err != nil {
+		t.Fatalf("ReceiveReader returned %v", err)
+	}
+	if p, err := ioutil.ReadAll(r); err != nil || string(p) != "bar" {
+		t.Errorf("ReadAll returned %q, %v, want bar, nil", p, err)
+	}
+
+	expected := []interface{}{[]byte("invalidate"), []interface{}{[]byte("foo")}}
+	if len(pushes) != 3 {
+		t.Fatalf("handler called %d times, want 3", len(pushes))
+	}
+	for _, p := range pushes {
+		if !reflect.DeepEqual(p, expected) {
+			t.Errorf("push = %v, want %v", p, expected)
+		}
+	}
+
+	// A null push frame is dropped.
+	rw.Reader.Reset(strings.NewReader(">-1\r\n+OK\r\n"))
+	if v, err := c.Receive(); err != nil || v != "OK" {
+		t.Errorf("Receive returned %v, %v, want OK, nil", v, err)
+	}
+	if len(pushes) != 3 {
+		t.Errorf("handler called %d times, want 3", len(pushes))
+	}
+
+	// Without a handler, push messages are returned from Receive.
+	rw.Reader.Reset(strings.NewReader(push))
+	redis.OnPush(c, nil)
+	if v, err := c.Receive(); err != nil || !reflect.DeepEqual(v, expected) {
+		t.Errorf("Receive returned %v, %v, want %v, nil", v, err, expected)
+	}
+}
+
+func TestDoRaw(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "GET" {
+			return "$3\r\nbar\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	get := redis.EncodeCommand("GET", "foo")
+	if expected := "*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n"; string(get) != expected {
+		t.Errorf("EncodeCommand returned %q, want %q", get, expected)
+	}
+
+	c.Send("SET", "foo", "bar")
+	for i := 0; i < 2; i++ {
+		v, err := redis.String(redis.DoRaw(c, get))
+		if err != nil || v != "bar" {
+			t.Errorf("DoRaw returned %q, %v, want bar, nil", v, err)
+		}
+	}
+
+	expected := [][]string{{"SET", "foo", "bar"}, {"GET", "foo"}, {"GET", "foo"}}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+}
+
+func TestDialOnConnect(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.close()
+
+	setName := func(c redis.Conn) error {
+		_, err := c.Do("CLIENT", "SETNAME", "worker")
+		return err
+	}
+	c, err := redis.Dial("tcp", s.addr(), redis.DialDatabase(2), redis.DialOnConnect(setName))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	c.Close()
+
+	expected := [][]string{{"SELECT", "2"}, {"CLIENT", "SETNAME", "worker"}}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+
+	var conn redis.Conn
+	errSetup := errors.New("setup failed")
+	_, err = redis.Dial("tcp", s.addr(), redis.DialOnConnect(func(c redis.Conn) error {
+		conn = c
+		return errSetup
+	}))
+	if err != errSetup {
+		t.Fatalf("Dial returned %v, want %v", err, errSetup)
+	}
+	if _, err := conn.Do("PING"); err == nil {
+		t.Errorf("connection is usable after failed setup")
+	}
+}
+
+func TestDialClientName(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr(), redis.DialClientName("worker"))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	c.Close()
+
+	expected := [][]string{{"CLIENT", "SETNAME", "worker"}}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+
+	old := newFakeServer(t, func(args []string) string {
+		return "-ERR unknown command 'CLIENT'\r\n"
+	})
+	defer old.close()
+
+	_, err = redis.Dial("tcp", old.addr(), redis.DialClientName("worker"))
+	if err == nil || !strings.Contains(err.Error(), "CLIENT SETNAME") {
+		t.Errorf("Dial returned %v, want CLIENT SETNAME error", err)
+	}
+}
+
+func TestDialKeepAlive(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.close()
+
+	var tc *net.TCPConn
+	dial := func(network, addr string) (net.Conn, error) {
+		c, err := net.Dial(network, addr)
+		tc, _ = c.(*net.TCPConn)
+		return c, err
+	}
+	c, err := redis.Dial("tcp", s.addr(), redis.DialNetDial(dial), redis.DialKeepAlive(77*time.Second))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Do("PING"); err != nil {
+		t.Fatalf("Do(PING) returned %v", err)
+	}
+	if tc == nil {
+		t.Fatal("dial function did not return a *net.TCPConn")
+	}
+	on, idle, err := tcpKeepAlive(tc)
+	if err != nil {
+		t.Skipf("cannot inspect keep-alive: %v", err)
+	}
+	if !on || idle != 77*time.Second {
+		t.Errorf("keep-alive = %v with idle %v, want true with idle %v", on, idle, 77*time.Second)
+	}
+}
+
+func TestDialNoDelay(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.close()
+
+	for _, noDelay := range []bool{true, false} {
+		var tc *net.TCPConn
+		dial := func(network, addr string) (net.Conn, error) {
+			c, err := net.Dial(network, addr)
+			tc, _ = c.(*net.TCPConn)
+			return c, err
+		}
+		c, err := redis.Dial("tcp", s.addr(), redis.DialNetDial(dial), redis.DialNoDelay(noDelay))
+		if err != nil {
+			t.Fatalf("Dial(DialNoDelay(%v)) returned %v", noDelay, err)
+		}
+		if _, err := c.Do("PING"); err != nil {
+			t.Errorf("Do(PING) with DialNoDelay(%v) returned %v", noDelay, err)
+		}
+		if tc == nil {
+			t.Fatal("dial function did not return a *net.TCPConn")
+		}
+		actual, err := tcpNoDelay(tc)
+		c.Close()
+		if err != nil {
+			t.Skipf("cannot inspect TCP_NODELAY: %v", err)
+		}
+		if actual != noDelay {
+			t.Errorf("TCP_NODELAY with DialNoDelay(%v) = %v", noDelay, actual)
+		}
+	}
+}
+
+func TestDialNetDial(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.close()
+
+	var dialed []string
+	dial := func(network, addr string) (net.Conn, error) {
+		dialed = append(dialed, network+" "+addr)
+		return net.Dial("tcp", s.addr())
+	}
+	c, err := redis.Dial("tcp", "redis.example.com:6379", redis.DialNetDial(dial), redis.DialDatabase(3))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	if expected := []string{"tcp redis.example.com:6379"}; !reflect.DeepEqual(dialed, expected) {
+		t.Errorf("dialed %v, want %v", dialed, expected)
+	}
+	expected := [][]string{{"SELECT", "3"}}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+
+	_, err = redis.Dial("tcp", "redis.example.com:6379", redis.DialNetDial(func(string, string) (net.Conn, error) {
+		return nil, errors.New("proxy refused")
+	}))
+	if err == nil {
+		t.Error("Dial with failing dial function returned nil error")
+	}
+}
+
+func TestDialOptionError(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "AUTH" {
+			return "-ERR invalid password\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	_, err := redis.Dial("tcp", s.addr(), redis.DialPassword("secret"), redis.DialDatabase(9))
+	if _, ok := err.(redis.Error); !ok {
+		t.Fatalf("Dial returned %v, want redis.Error", err)
+	}
+
+	expected := [][]string{{"AUTH", "secret"}}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+}
+
+func TestDialUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "redigo")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir returned %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	l, err := net.Listen("unix", filepath.Join(dir, "redis.sock"))
+	if err != nil {
+		t.Fatalf("net.Listen returned %v", err)
+	}
+	s := newFakeServerListener(l, nil)
+	defer s.close()
+
+	c, err := redis.DialUnix(s.addr(), redis.DialDatabase(1), redis.DialReadTimeout(time.Second), redis.DialWriteTimeout(time.Second), redis.DialKeepAlive(time.Minute))
+	if err != nil {
+		t.Fatalf("DialUnix returned %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Do("PING"); err != nil {
+		t.Fatalf("Do(PING) returned %v", err)
+	}
+
+	expected := [][]string{{"SELECT", "1"}, {"PING"}}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+}
+
+var dialURLErrorTests = []string{
+	"http://localhost",
+	"redis://localhost/abc",
+	"redis://localhost/1/2",
+}
+
+func TestDialURLErrors(t *testing.T) {
+	for _, rawurl := range dialURLErrorTests {
+		if _, err := redis.DialURL(rawurl); err == nil {
+			t.Errorf("DialURL(%q) did not return error", rawurl)
+		}
+	}
 }
 
 // Connect to local instance of Redis running on the default port.
-func ExampleDial(x int) {
+func ExampleDial() {
 	c, err := redis.Dial("tcp", ":6379")
 	if err != nil {
 		// handle error
@@ -20,9 +20,11 @@
 // Connections
 //
 // The Conn interface is the primary interface for working with Redis.
-// Applications create connections by calling the Dial, DialWithTimeout or
-// NewConn functions. In the future, functions will be added for creating
-// sharded and other types of connections.
+// Applications create connections by calling the Dial, DialTimeout, DialTLS,
+// DialUnix, DialURL or NewConn functions. In the future, functions will be
+// added for creating sharded and other types of connections. The
+// NewReconnectConn function returns a connection that dials a new connection
+// when the current connection fails.
 //
 // The application must call the connection Close method when the application
 // is done with the connection.
@@ -35,9 +37,17 @@
 //
 // Arguments of type string and []byte are sent to the server as is. The value
 // false is converted to "0" and the value true is converted to "1". The value
-// nil is converted to "". All other values are converted to a string using the
-// fmt.Fprint function. Command replies are represented using the following Go
-// types:
+// nil is converted to "". Values of type float64 and float32 are converted
+// using strconv.FormatFloat with the 'g' format and the smallest precision that
+// represents the value exactly. A time.Time value is converted to the number of
+// seconds since the Unix epoch. A time.Duration value is converted to a whole
+// number of seconds; a positive fraction of a second is rounded up, so that
+// 500*time.Millisecond is sent as 1. Use an integer number of milliseconds for
+// commands such as PEXPIRE. All other values are converted to a string using
+// the fmt.Fprint function. Slice arguments are not expanded; a []string
+// argument is sent as a single value formatted by fmt.Fprint. Use Args.AddFlat
+// or DoFlat to expand a slice to separate arguments. Command replies are
+// represented using the following Go types:
 //
 //  Redis type          Go type
 //  error               redis.Error
@@ -45,9 +55,45 @@
 //  status              string
 //  bulk                []byte or nil if value not present.
 //  multi-bulk          []interface{} or nil if value not present.
+//  null (RESP3)        nil
+//  map (RESP3)         []interface{} of alternating keys and values
+//  set (RESP3)         []interface{}
+//  push (RESP3)        []interface{} unless handled by OnPush
+//  double (RESP3)      float64
+//  boolean (RESP3)     bool
+//  verbatim (RESP3)    []byte without the format prefix
+//  big number (RESP3)  *big.Int
+//
+// The []byte values in replies are allocated for each reply and do not alias
+// the connection's read buffer. The application owns the values and can
+// retain or modify them after subsequent reads from the connection. Use the
+// ReceiveReader function to read a large bulk value without allocating a
+// slice for the value.
+//
+// RESP3 maps are not represented with a distinct type. A map reply is flattened
+// to the alternating keys and values returned by RESP2 for the same command, so
+// helpers such as StringMap and ScanStruct work with both protocols. In the
+// same way, the reply helpers and the Scan functions convert a RESP3 double to
+// a bulk value formatted with strconv.FormatFloat, a boolean to the integer 1
+// or 0 and a big number to a bulk value with its decimal digits before
+// converting the value. For example, String returns the score from a ZSCORE
+// reply with both protocols.
 //
 // Applications can use type assertions or type switches to determine the type
-// of a reply.
+// of a reply. Because status replies are represented as string and bulk
+// replies are represented as []byte, the type of a reply distinguishes the
+// status reply +OK from a bulk reply containing the value OK:
+//
+//  switch reply := reply.(type) {
+//  case string:
+//      // status reply
+//  case []byte:
+//      // bulk reply
+//  case int64:
+//      // integer reply
+//  case []interface{}:
+//      // multi-bulk reply
+//  }
 //
 // Pipelining
 //
@@ -67,6 +113,10 @@
 //  c.Receive() // reply from SET
 //  v, err = c.Receive() // reply from GET
 //
+// The DoMulti function sends a batch of commands with a single flush and
+// receives exactly one reply for each command. The ReceiveN function receives
+// a given number of replies after pipelining commands with Send and Flush.
+//
 // The Do method combines the functionality of the Send, Flush and Receive
 // methods. The Do method starts by writing the command and flushing the output
 // buffer. Next, the Do method receives all pending replies including the reply
@@ -82,6 +132,15 @@
 //  r, err := c.Do("EXEC")
 //  fmt.Println(r) // prints [1, 1]
 //
+// The Transaction function wraps the commands queued by a function in MULTI
+// and EXEC:
+//
+//  r, err := redis.Transaction(c, func(tx *redis.Tx) error {
+//      tx.Send("INCR", "foo")
+//      tx.Send("INCR", "bar")
+//      return nil
+//  })
+//
 // Thread Safety
 //
 // The connection Send and Flush methods cannot be called concurrently with
@@ -107,7 +166,8 @@
 //
 // The PubSubConn type wraps a Conn with convenience methods for implementing
 // subscribers. The Subscribe, PSubscribe, Unsubscribe and PUnsubscribe methods
-// send and flush a subscription management command. The receive method
+// send and flush a subscription management command. The Ping method sends a
+// PING that the server answers with a Pong notification. The receive method
 // converts a pushed message to convenient types for use in a type switch.
 //
 //  psc := PubSubConn{c}
@@ -120,15 +180,41 @@
 //          fmt.Printf("%s: %s %d\n", v.Channel, v.Kind, v.Count)
 //      case error:
 //          return v
+//      }
+//  }
+//
+// Monitor
+//
+// Use the Send, Flush and Receive methods to stream the commands processed by
+// the server. After the MONITOR command, each call to Receive returns a
+// monitored command as a string.
+//
+//  c.Send("MONITOR")
+//  c.Flush()
+//  if _, err := c.Receive(); err != nil { // reply to MONITOR
+//      return err
+//  }
+//  for {
+//      line, err := redis.String(c.Receive())
+//      if err != nil {
+//          return err
+//      }
+//      fmt.Println(line)
 //  }
 //
+// The server does not process further commands from a connection in monitor
+// mode. Close the connection when done. Use the Discard function to close a
+// connection obtained from a pool so that the connection is not reused.
+//
 // Reply Helpers
 //
-// The Bool, Int, Bytes, String and Values functions convert a reply to a value
-// of a specific type. To allow convenient wrapping of calls to the connection
-// Do and Receive methods, the functions take a second argument of type error.
-// If the error is non-nil, then the helper function returns the error. If the
-// error is nil, the function converts the reply to the specified type:
+// The Bool, Int, Int64, Uint64, Float64, Bytes, String, Strings, ByteSlices,
+// Ints, Int64s, Positions, StringMap, IntMap, Int64Map, Float64Map and Values
+// functions convert a reply to a value of a specific type. To allow convenient
+// wrapping of calls to the connection Do and Receive methods, the functions
+// take a second argument of type error. If the error is non-nil, then the
+// helper function returns the error. If the error is nil, the function converts
+// the reply to the specified type or returns ErrNil if the reply is nil:
 //
 //  exists, err := redis.Bool(c.Do("EXISTS", "foo"))
 //  if err != nil {
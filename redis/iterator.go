@@ -0,0 +1,114 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"strings"
+)
+
+// ScanIterator iterates over the elements returned by the SCAN, SSCAN, HSCAN
+// and ZSCAN commands. The iterator issues commands with the cursor returned by
+// the previous command until the server returns the cursor 0.
+//
+// The following example prints all keys that match a pattern:
+//
+//  iter := redis.NewScanIterator(c, "SCAN", "MATCH", "user:*")
+//  for iter.Next() {
+//      fmt.Printf("%s\n", iter.Val())
+//  }
+//  if err := iter.Err(); err != nil {
+//      // handle error
+//  }
+//
+// The elements returned by HSCAN and ZSCAN alternate between the field or
+// member and the value or score.
+type ScanIterator struct {
+	c      Conn
+	cmd    string
+	key    interface{}
+	args   []interface{}
+	cursor []byte
+	items  []interface{}
+	val    []byte
+	done   bool
+	err    error
+}
+
+// NewScanIterator returns an iterator for the given SCAN family command. For
+// the SSCAN, HSCAN and ZSCAN commands, the first argument is the key. The
+// remaining arguments are options such as MATCH and COUNT that are sent with
+// each command after the cursor.
+func NewScanIterator(c Conn, commandName string, args ...interface{}) *ScanIterator {
+	iter := &ScanIterator{c: c, cmd: commandName, cursor: []byte("0")}
+	if !strings.EqualFold(commandName, "SCAN") {
+		if len(args) == 0 {
+			iter.err = errors.New("redigo: " + commandName + " iterator requires a key")
+			return iter
+		}
+		iter.key, args = args[0], args[1:]
+	}
+	iter.args = args
+	return iter
+}
+
+// Next advances the iterator to the next element. Next returns false when
+// there are no more elements or when an error occurs.
+func (iter *ScanIterator) Next() bool {
+	for len(iter.items) == 0 {
+		if iter.done || iter.err != nil {
+			iter.val = nil
+			return false
+		}
+		iter.fetch()
+	}
+	var ok bool
+	iter.val, ok = iter.items[0].([]byte)
+	iter.items = iter.items[1:]
+	if !ok {
+		iter.err = errors.New("redigo: " + iter.cmd + " element not a bulk value")
+		return false
+	}
+	return true
+}
+
+func (iter *ScanIterator) fetch() {
+	args := make([]interface{}, 0, 2+len(iter.args))
+	if iter.key != nil {
+		args = append(args, iter.key)
+	}
+	args = append(args, iter.cursor)
+	args = append(args, iter.args...)
+	reply, err := Values(iter.c.Do(iter.cmd, args...))
+	if err != nil {
+		iter.err = err
+		return
+	}
+	if _, err := Scan(reply, &iter.cursor, &iter.items); err != nil {
+		iter.err = err
+		return
+	}
+	iter.done = string(iter.cursor) == "0"
+}
+
+// Val returns the current element.
+func (iter *ScanIterator) Val() []byte {
+	return iter.val
+}
+
+// Err returns the first error encountered by the iterator.
+func (iter *ScanIterator) Err() error {
+	return iter.err
+}
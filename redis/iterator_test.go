@@ -0,0 +1,82 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"testing"
+)
+
+func TestScanIterator(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[2] {
+		case "0":
+			return "*2\r\n$1\r\n5\r\n*2\r\n$2\r\nf1\r\n$2\r\nv1\r\n"
+		case "5":
+			return "*2\r\n$1\r\n7\r\n*0\r\n"
+		}
+		return "*2\r\n$1\r\n0\r\n*2\r\n$2\r\nf2\r\n$2\r\nv2\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	var actual []string
+	iter := redis.NewScanIterator(c, "HSCAN", "hash", "COUNT", 2)
+	for iter.Next() {
+		actual = append(actual, string(iter.Val()))
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatalf("iterator returned error %v", err)
+	}
+	if expected := []string{"f1", "v1", "f2", "v2"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("iterator returned %v, want %v", actual, expected)
+	}
+
+	expected := [][]string{
+		{"HSCAN", "hash", "0", "COUNT", "2"},
+		{"HSCAN", "hash", "5", "COUNT", "2"},
+		{"HSCAN", "hash", "7", "COUNT", "2"},
+	}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+}
+
+func TestScanIteratorError(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		return "-ERR invalid cursor\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	iter := redis.NewScanIterator(c, "SCAN")
+	if iter.Next() {
+		t.Fatalf("Next returned true, want false")
+	}
+	if _, ok := iter.Err().(redis.Error); !ok {
+		t.Errorf("iterator returned error %v, want redis.Error", iter.Err())
+	}
+}
@@ -16,11 +16,18 @@ package redis
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"time"
 )
 
-// NewLoggingConn returns a logging wrapper around a connection.
+// NewLoggingConn returns a logging wrapper around a connection. The wrapper
+// logs each call to Do, Send, Receive and Close with the command arguments and
+// the result. String and byte slice values longer than 32 bytes and arrays
+// with more than 32 elements are truncated in the log output.
 func NewLoggingConn(conn Conn, logger *log.Logger, prefix string) Conn {
 	if prefix != "" {
 		prefix = prefix + "."
@@ -42,6 +49,14 @@ func (c *loggingConn) Close() error {
 	return err
 }
 
+func (c *loggingConn) Discard() error {
+	err := Discard(c.Conn)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%sDiscard() -> (%v)", c.prefix, err)
+	c.logger.Output(2, buf.String())
+	return err
+}
+
 func (c *loggingConn) printValue(buf *bytes.Buffer, v interface{}) {
 	const chop = 32
 	switch v := v.(type) {
@@ -84,13 +99,15 @@ func (c *loggingConn) print(method, commandName string, args []interface{}, repl
 	fmt.Fprintf(&buf, "%s%s(", c.prefix, method)
 	if method != "Receive" {
 		buf.WriteString(commandName)
-		for _, arg := range args {
-			buf.WriteString(", ")
+		for i, arg := range args {
+			if i > 0 || commandName != "" {
+				buf.WriteString(", ")
+			}
 			c.printValue(&buf, arg)
 		}
 	}
 	buf.WriteString(") -> (")
-	if method != "Send" {
+	if method != "Send" && method != "SendInline" {
 		c.printValue(&buf, reply)
 		buf.WriteString(", ")
 	}
@@ -104,14 +121,85 @@ func (c *loggingConn) Do(commandName string, args ...interface{}) (interface{},
 	return reply, err
 }
 
+func (c *loggingConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	reply, err := DoWithTimeout(c.Conn, timeout, commandName, args...)
+	c.print("DoWithTimeout", commandName, args, reply, err)
+	return reply, err
+}
+
+func (c *loggingConn) DoContext(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	reply, err := DoContext(c.Conn, ctx, commandName, args...)
+	c.print("DoContext", commandName, args, reply, err)
+	return reply, err
+}
+
+func (c *loggingConn) DoRaw(serialized []byte) (interface{}, error) {
+	reply, err := DoRaw(c.Conn, serialized)
+	c.print("DoRaw", "", []interface{}{serialized}, reply, err)
+	return reply, err
+}
+
+func (c *loggingConn) Ping() error {
+	err := Ping(c.Conn)
+	c.print("Ping", "", nil, nil, err)
+	return err
+}
+
 func (c *loggingConn) Send(commandName string, args ...interface{}) error {
 	err := c.Conn.Send(commandName, args...)
 	c.print("Send", commandName, args, nil, err)
 	return err
 }
 
+func (c *loggingConn) SendInline(parts ...string) error {
+	err := SendInline(c.Conn, parts...)
+	var commandName string
+	var args []interface{}
+	if len(parts) > 0 {
+		commandName = parts[0]
+		for _, p := range parts[1:] {
+			args = append(args, p)
+		}
+	}
+	c.print("SendInline", commandName, args, nil, err)
+	return err
+}
+
 func (c *loggingConn) Receive() (interface{}, error) {
 	reply, err := c.Conn.Receive()
 	c.print("Receive", "", nil, reply, err)
 	return reply, err
 }
+
+func (c *loggingConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	reply, err := ReceiveWithTimeout(c.Conn, timeout)
+	c.print("ReceiveWithTimeout", "", nil, reply, err)
+	return reply, err
+}
+
+func (c *loggingConn) ReceiveWithKind() (byte, interface{}, error) {
+	kind, reply, err := ReceiveWithKind(c.Conn)
+	c.print("ReceiveWithKind", "", nil, reply, err)
+	return kind, reply, err
+}
+
+func (c *loggingConn) ReceiveReader() (io.Reader, error) {
+	r, err := ReceiveReader(c.Conn)
+	c.print("ReceiveReader", "", nil, nil, err)
+	return r, err
+}
+
+func (c *loggingConn) CopyReply(w io.Writer) error {
+	err := CopyReply(c.Conn, w)
+	c.print("CopyReply", "", nil, nil, err)
+	return err
+}
+
+func (c *loggingConn) NetConn() net.Conn {
+	nc, _ := NetConn(c.Conn)
+	return nc
+}
+
+func (c *loggingConn) OnPush(f func(push []interface{})) {
+	OnPush(c.Conn, f)
+}
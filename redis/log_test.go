@@ -0,0 +1,72 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"bytes"
+	"github.com/garyburd/redigo/redis"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestLoggingConn(t *testing.T) {
+	long := strings.Repeat("x", 40)
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "GET":
+			return "$40\r\n" + long + "\r\n"
+		case "FAIL":
+			return "-ERR fail\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned error %v", err)
+	}
+	var buf bytes.Buffer
+	c = redis.NewLoggingConn(c, log.New(&buf, "", 0), "test")
+
+	if _, err := c.Do("SET", "foo", long); err != nil {
+		t.Fatalf("Do(SET) returned error %v", err)
+	}
+	if _, err := c.Do("GET", "foo"); err != nil {
+		t.Fatalf("Do(GET) returned error %v", err)
+	}
+	if _, err := c.Do("FAIL"); err == nil {
+		t.Fatalf("Do(FAIL) did not return error")
+	}
+	c.Send("PING")
+	c.Flush()
+	c.Receive()
+	c.Close()
+
+	chopped := `"` + long[:32] + `"...`
+	expected := []string{
+		`test.Do(SET, "foo", ` + chopped + `) -> ("OK", <nil>)`,
+		`test.Do(GET, "foo") -> (` + chopped + `, <nil>)`,
+		`test.Do(FAIL) -> (ERR fail, ERR fail)`,
+		`test.Send(PING) -> (<nil>)`,
+		`test.Receive() -> ("OK", <nil>)`,
+		`test.Close() -> (<nil>)`,
+		``,
+	}
+	if actual := buf.String(); actual != strings.Join(expected, "\n") {
+		t.Errorf("log output:\n%s\nwant:\n%s", actual, strings.Join(expected, "\n"))
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+)
+
+// DoMulti sends a batch of commands to the server using a single flush and
+// returns the replies in the order that the commands were sent. Each command
+// is a slice with the command name as the first element followed by the
+// command arguments:
+//
+//  replies, err := redis.DoMulti(c, [][]interface{}{
+//      {"SET", "foo", "bar"},
+//      {"GET", "foo"},
+//  })
+//
+// If a reply is an error, then DoMulti returns the replies preceding the error
+// reply and the error. The remaining replies are read from the connection and
+// discarded so that the connection can be used for subsequent commands. If
+// the connection fails, then DoMulti returns the replies received before the
+// failure and the connection error.
+func DoMulti(c Conn, commands [][]interface{}) ([]interface{}, error) {
+	for _, cmd := range commands {
+		if len(cmd) == 0 {
+			return nil, errors.New("redigo: DoMulti command is empty")
+		}
+		if _, ok := cmd[0].(string); !ok {
+			return nil, errors.New("redigo: DoMulti command name is not a string")
+		}
+	}
+	for _, cmd := range commands {
+		if err := c.Send(cmd[0].(string), cmd[1:]...); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make([]interface{}, 0, len(commands))
+	var replyErr error
+	for i := 0; i < len(commands); i++ {
+		reply, err := c.Receive()
+		if err != nil {
+			if _, ok := err.(Error); !ok {
+				return replies, err
+			}
+			if replyErr == nil {
+				replyErr = err
+			}
+		}
+		if replyErr == nil {
+			replies = append(replies, reply)
+		}
+	}
+	return replies, replyErr
+}
+
+// ReceiveN receives n replies from the connection and returns the replies in
+// the order received. Use ReceiveN to read the replies to commands pipelined
+// with Send and Flush:
+//
+//  c.Send("SET", "foo", "bar")
+//  c.Send("GET", "foo")
+//  c.Flush()
+//  replies, err := redis.ReceiveN(c, 2)
+//
+// Error replies are returned as elements of type Error in the result. If the
+// connection fails, then ReceiveN returns the replies received before the
+// failure and the connection error.
+func ReceiveN(c Conn, n int) ([]interface{}, error) {
+	replies := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		reply, err := c.Receive()
+		if err != nil {
+			if _, ok := err.(Error); !ok {
+				return replies, err
+			}
+			reply = err
+		}
+		replies = append(replies, reply)
+	}
+	return replies, nil
+}
+
+// BufferedSender sends commands to a connection and flushes the connection
+// when the number of unflushed commands or the size of the encoded commands
+// reaches a threshold. Use a BufferedSender to load large amounts of data with
+// bounded memory use:
+//
+//  s := redis.NewBufferedSender(c, 1000, 0)
+//  for k, v := range values {
+//      if err := s.Send("SET", k, v); err != nil {
+//          // handle error
+//      }
+//  }
+//  if err := s.Flush(); err != nil {
+//      // handle error
+//  }
+//
+// After each flush, the sender receives and discards the replies to the
+// flushed commands so that replies do not accumulate at the server. The
+// application must not call Do, Send, Flush or Receive on the connection
+// while commands sent with the sender are unflushed.
+type BufferedSender struct {
+	c           Conn
+	maxCommands int
+	maxBytes    int
+	n           int
+	size        int
+}
+
+// NewBufferedSender returns a sender for c that flushes after maxCommands
+// commands or after maxBytes bytes of encoded commands. The size of encoded
+// commands is computed from the argument lengths and is estimated for arguments
+// that are not strings or byte slices. A zero threshold is ignored. If both
+// thresholds are zero, then commands are flushed only by calls to Flush.
+func NewBufferedSender(c Conn, maxCommands, maxBytes int) *BufferedSender {
+	return &BufferedSender{c: c, maxCommands: maxCommands, maxBytes: maxBytes}
+}
+
+// Send writes the command to the connection's output buffer and flushes the
+// connection if a threshold is reached. Send returns the error from the
+// flush, if any.
+func (s *BufferedSender) Send(cmd string, args ...interface{}) error {
+	if err := s.c.Send(cmd, args...); err != nil {
+		return err
+	}
+	s.n++
+	if s.maxBytes > 0 {
+		s.size += commandSize(cmd, args)
+	}
+	if (s.maxCommands > 0 && s.n >= s.maxCommands) || (s.maxBytes > 0 && s.size >= s.maxBytes) {
+		return s.Flush()
+	}
+	return nil
+}
+
+// commandSize returns the size of the wire encoding of a command without
+// encoding the command. The size is exact for string, []byte, bool and nil
+// arguments. The size of other arguments is estimated.
+func commandSize(cmd string, args []interface{}) int {
+	n := lenSize(1+len(args)) + bulkSize(len(cmd))
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case string:
+			n += bulkSize(len(arg))
+		case []byte:
+			n += bulkSize(len(arg))
+		case bool:
+			n += bulkSize(1)
+		case nil:
+			n += bulkSize(0)
+		default:
+			n += bulkSize(20)
+		}
+	}
+	return n
+}
+
+// lenSize returns the size of a length line such as "*3\r\n".
+func lenSize(n int) int {
+	size := 4
+	for ; n >= 10; n /= 10 {
+		size++
+	}
+	return size
+}
+
+// bulkSize returns the size of a bulk string with n bytes.
+func bulkSize(n int) int {
+	return lenSize(n) + n + 2
+}
+
+// Flush flushes the connection and receives the replies to the commands sent
+// since the previous flush. Flush returns the first error reply or the
+// connection error, if any.
+func (s *BufferedSender) Flush() error {
+	n := s.n
+	s.n = 0
+	s.size = 0
+	if err := s.c.Flush(); err != nil {
+		return err
+	}
+	var replyErr error
+	for i := 0; i < n; i++ {
+		if _, err := s.c.Receive(); err != nil {
+			if _, ok := err.(Error); !ok {
+				return err
+			}
+			if replyErr == nil {
+				replyErr = err
+			}
+		}
+	}
+	return replyErr
+}
+
+// Pipeline queues commands with a handler for each reply. Use a Pipeline to
+// keep the handling of a reply with the command that produced it:
+//
+//  p := redis.NewPipeline(c)
+//  p.Add("GET", "foo").Then(func(reply interface{}, err error) {
+//      foo, err = redis.String(reply, err)
+//  })
+//  p.Add("INCR", "counter").Then(func(reply interface{}, err error) {
+//      n, err = redis.Int(reply, err)
+//  })
+//  if err := p.Run(); err != nil {
+//      // handle error
+//  }
+type Pipeline struct {
+	c        Conn
+	commands []*PipelineCommand
+}
+
+// PipelineCommand is a command queued on a Pipeline.
+type PipelineCommand struct {
+	cmd  string
+	args []interface{}
+	then func(reply interface{}, err error)
+}
+
+// NewPipeline returns a pipeline for c.
+func NewPipeline(c Conn) *Pipeline {
+	return &Pipeline{c: c}
+}
+
+// Add queues a command on the pipeline. The command is sent by Run.
+func (p *Pipeline) Add(cmd string, args ...interface{}) *PipelineCommand {
+	pc := &PipelineCommand{cmd: cmd, args: args}
+	p.commands = append(p.commands, pc)
+	return pc
+}
+
+// Then sets the function called with the reply to the command. Error replies
+// are passed to the function as the error.
+func (pc *PipelineCommand) Then(f func(reply interface{}, err error)) {
+	pc.then = f
+}
+
+// Run sends the queued commands with a single flush, receives the replies in
+// order and calls the handler for each command with its reply. If the
+// connection fails, then the handlers for the remaining commands are called
+// with the connection error and Run returns the connection error. Error
+// replies are passed to the handlers and are not returned by Run. The
+// pipeline is empty after Run returns.
+func (p *Pipeline) Run() error {
+	commands := p.commands
+	p.commands = nil
+	var connErr error
+	for _, pc := range commands {
+		if connErr = p.c.Send(pc.cmd, pc.args...); connErr != nil {
+			break
+		}
+	}
+	if connErr == nil {
+		connErr = p.c.Flush()
+	}
+	for _, pc := range commands {
+		var reply interface{}
+		err := connErr
+		if err == nil {
+			reply, err = p.c.Receive()
+			if _, ok := err.(Error); !ok && err != nil {
+				connErr = err
+			}
+		}
+		if pc.then != nil {
+			pc.then(reply, err)
+		}
+	}
+	return connErr
+}
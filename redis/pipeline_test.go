@@ -0,0 +1,253 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"bufio"
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDoMulti(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "GET":
+			return "$3\r\nbar\r\n"
+		case "HSET":
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	replies, err := redis.DoMulti(c, [][]interface{}{
+		{"SET", "foo", "bar"},
+		{"GET", "foo"},
+	})
+	if err != nil {
+		t.Fatalf("DoMulti returned error %v", err)
+	}
+	if expected := []interface{}{"OK", []byte("bar")}; !reflect.DeepEqual(replies, expected) {
+		t.Errorf("DoMulti returned %v, want %v", replies, expected)
+	}
+
+	replies, err = redis.DoMulti(c, [][]interface{}{
+		{"SET", "foo", "bar"},
+		{"HSET", "foo", "field", "value"},
+		{"GET", "foo"},
+	})
+	if _, ok := err.(redis.Error); !ok {
+		t.Fatalf("DoMulti returned error %v, want redis.Error", err)
+	}
+	if expected := []interface{}{"OK"}; !reflect.DeepEqual(replies, expected) {
+		t.Errorf("DoMulti returned %v, want %v", replies, expected)
+	}
+
+	// The connection is usable after an error reply.
+	if v, err := redis.String(c.Do("GET", "foo")); err != nil || v != "bar" {
+		t.Errorf("Do(GET) returned %q, %v; want bar, nil", v, err)
+	}
+}
+
+func TestReceiveN(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "GET":
+			return "$3\r\nbar\r\n"
+		case "HSET":
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	c.Send("SET", "foo", "bar")
+	c.Send("HSET", "foo", "field", "value")
+	c.Send("GET", "foo")
+	c.Flush()
+	replies, err := redis.ReceiveN(c, 3)
+	if err != nil {
+		t.Fatalf("ReceiveN returned error %v", err)
+	}
+	expected := []interface{}{
+		"OK",
+		redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value"),
+		[]byte("bar"),
+	}
+	if !reflect.DeepEqual(replies, expected) {
+		t.Errorf("ReceiveN returned %v, want %v", replies, expected)
+	}
+
+	// The connection fails after the first reply.
+	rw := bufio.ReadWriter{
+		Reader: bufio.NewReader(strings.NewReader("$3\r\nbar\r\n")),
+		Writer: bufio.NewWriter(nil),
+	}
+	replies, err = redis.ReceiveN(redis.NewConnBufio(rw), 2)
+	if err == nil {
+		t.Fatalf("ReceiveN did not return error after connection failure")
+	}
+	if expected := []interface{}{[]byte("bar")}; !reflect.DeepEqual(replies, expected) {
+		t.Errorf("ReceiveN returned %v, want %v", replies, expected)
+	}
+}
+
+type flushCountingConn struct {
+	redis.Conn
+	flushes int
+}
+
+func (c *flushCountingConn) Flush() error {
+	c.flushes++
+	return c.Conn.Flush()
+}
+
+func TestBufferedSender(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "HSET" {
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	tests := []struct {
+		name        string
+		maxCommands int
+		maxBytes    int
+		flushes     int
+	}{
+		{"commands", 2, 0, 3},
+		{"bytes", 0, 50, 3},
+		// Each command is encoded in 27 bytes.
+		{"bytes exact", 0, 54, 3},
+		{"bytes exact plus one", 0, 55, 2},
+		{"none", 0, 0, 1},
+	}
+	for _, tt := range tests {
+		c, err := redis.Dial("tcp", s.addr())
+		if err != nil {
+			t.Fatalf("Dial returned %v", err)
+		}
+		fc := &flushCountingConn{Conn: c}
+		bs := redis.NewBufferedSender(fc, tt.maxCommands, tt.maxBytes)
+		for i := 0; i < 5; i++ {
+			if err := bs.Send("SET", "k", "v"); err != nil {
+				t.Fatalf("%s: Send returned %v", tt.name, err)
+			}
+		}
+		if err := bs.Flush(); err != nil {
+			t.Fatalf("%s: Flush returned %v", tt.name, err)
+		}
+		if fc.flushes != tt.flushes {
+			t.Errorf("%s: flushes = %d, want %d", tt.name, fc.flushes, tt.flushes)
+		}
+		if v, err := c.Do("PING"); err != nil || v != "OK" {
+			t.Errorf("%s: Do(PING) returned %v, %v, want OK, nil", tt.name, v, err)
+		}
+		c.Close()
+	}
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+	bs := redis.NewBufferedSender(c, 3, 0)
+	bs.Send("SET", "foo", "bar")
+	bs.Send("HSET", "foo", "field", "value")
+	if err := bs.Send("SET", "foo", "baz"); err == nil {
+		t.Error("Send did not return error reply")
+	}
+	if v, err := c.Do("PING"); err != nil || v != "OK" {
+		t.Errorf("Do(PING) after error reply returned %v, %v, want OK, nil", v, err)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "GET":
+			return "$3\r\nbar\r\n"
+		case "INCR":
+			return ":1\r\n"
+		case "HSET":
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	var (
+		foo    string
+		n      int
+		hsetOK bool
+		order  []string
+	)
+	p := redis.NewPipeline(c)
+	p.Add("GET", "foo").Then(func(reply interface{}, err error) {
+		order = append(order, "GET")
+		foo, err = redis.String(reply, err)
+		if err != nil {
+			t.Errorf("GET handler received error %v", err)
+		}
+	})
+	p.Add("HSET", "foo", "field", "value").Then(func(reply interface{}, err error) {
+		order = append(order, "HSET")
+		_, hsetOK = err.(redis.Error)
+	})
+	p.Add("SET", "k", "v")
+	p.Add("INCR", "counter").Then(func(reply interface{}, err error) {
+		order = append(order, "INCR")
+		n, _ = redis.Int(reply, err)
+	})
+	if err := p.Run(); err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+	if foo != "bar" || n != 1 || !hsetOK {
+		t.Errorf("handlers got foo=%q, n=%d, hsetOK=%v, want bar, 1, true", foo, n, hsetOK)
+	}
+	if expected := []string{"GET", "HSET", "INCR"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("handler order = %v, want %v", order, expected)
+	}
+
+	// The pipeline is empty after Run.
+	if err := p.Run(); err != nil {
+		t.Fatalf("Run of empty pipeline returned %v", err)
+	}
+	if v, err := c.Do("PING"); err != nil || v != "OK" {
+		t.Errorf("Do(PING) after Run returned %v, %v, want OK, nil", v, err)
+	}
+}
@@ -16,7 +16,10 @@ package redis
 
 import (
 	"container/list"
+	"context"
 	"errors"
+	"io"
+	"net"
 	"sync"
 	"time"
 )
@@ -25,9 +28,16 @@ var nowFunc = time.Now // for testing
 
 var errPoolClosed = errors.New("redigo: connection pool closed")
 
+// ErrPoolExhausted is returned from a pool connection method (Do, Send,
+// Receive, Flush, Err) when the maximum number of database connections in the
+// pool has been reached.
+var ErrPoolExhausted = errors.New("redigo: connection pool exhausted")
+
 // Pool maintains a pool of connections. The application calls the Get method
 // to get a connection from the pool and the connection's Close method to
-// return the connection's resources to the pool.
+// return the connection's resources to the pool. Connections with a non-nil
+// Err() are closed instead of being returned to the pool. A Pool is safe for
+// concurrent use by multiple goroutines.
 //
 // The following example shows how to use a pool in a web application. The
 // application creates a pool at application startup and makes it available to
@@ -41,29 +51,25 @@ var errPoolClosed = errors.New("redigo: connection pool closed")
 //              MaxIdle: 3,
 //              IdleTimeout: 240 * time.Second,
 //              Dial: func () (redis.Conn, error) {
-//                  c, err := redis.Dial("tcp", server)
-//                  if err != nil {
-//                      return nil, err
-//                  }
-//                  if err := c.Do("AUTH", password); err != nil {
-//                      c.Close()
-//                      return nil, err
+//                  return redis.Dial("tcp", server, redis.DialPassword(password))
+//              },
+//              TestOnBorrow: func(c redis.Conn, t time.Time) error {
+//                  if time.Since(t) < time.Minute {
+//                      return nil
 //                  }
-//                  return c, err
+//                  return redis.Ping(c)
 //              },
-//				TestOnBorrow: func(c redis.Conn, t time.Time) error {
-//				    _, err := c.Do("PING")
-//                  return err
-//			    },
 //          }
 //
-// This pool has a maximum of three connections to the server specified by the
-// variable "server". Each connection is authenticated using a password.
+// This pool has a maximum of three idle connections to the server specified
+// by the variable "server". Each connection is authenticated using a password.
+// Connections that have been idle for more than a minute are checked with the
+// Ping function before they are returned from Get.
 //
 // A request handler gets a connection from the pool and closes the connection
 // when the handler is done:
 //
-//  conn, err := pool.Get()
+//  conn := pool.Get()
 //  defer conn.Close()
 //  // do something with the connection
 type Pool struct {
@@ -74,21 +80,40 @@ type Pool struct {
 	// TestOnBorrow is an optional application supplied function for checking
 	// the health of an idle connection before the connection is used again by
 	// the application. Argument t is the time that the connection was returned
-	// to the pool. If the function returns an error, then the connection is
-	// closed.
+	// to the pool. Use t to skip the check for recently used connections. If
+	// the function returns an error, then the connection is closed and the
+	// pool tries the next idle connection or dials a new connection.
 	TestOnBorrow func(c Conn, t time.Time) error
 
 	// Maximum number of idle connections in the pool.
 	MaxIdle int
 
+	// Maximum number of connections allocated by the pool at a given time.
+	// When zero, there is no limit on the number of connections in the pool.
+	MaxActive int
+
 	// Close connections after remaining idle for this duration. If the value
 	// is zero, then idle connections are not closed. Applications should set
-	// the timeout to a value less than the server's timeout.
+	// the timeout to a value less than the server's timeout. The pool records
+	// the time that each connection is returned to the pool and closes stale
+	// connections when Get is called.
 	IdleTimeout time.Duration
 
+	// If Wait is true and the pool is at the MaxActive limit, then Get() waits
+	// for a connection to be returned to the pool before returning. If Wait is
+	// false and the pool is at the MaxActive limit, then Get() returns a
+	// connection with ErrPoolExhausted as the error.
+	Wait bool
+
 	// mu protects fields defined below.
 	mu     sync.Mutex
+	cond   *sync.Cond
 	closed bool
+	active int
+
+	// Statistics for waiting on the MaxActive limit.
+	waitCount    int64
+	waitDuration time.Duration
 
 	// Stack of idleConn with most recently used at the front.
 	idle list.List
@@ -105,34 +130,116 @@ func NewPool(newFn func() (Conn, error), maxIdle int) *Pool {
 	return &Pool{Dial: newFn, MaxIdle: maxIdle}
 }
 
-// Get gets a connection from the pool.
+// Get gets a connection. The application must close the returned connection.
+// This method always returns a valid connection so that applications can defer
+// error handling to the first use of the connection. If there is an error
+// getting an underlying connection, then the connection Err, Do, Send, Flush
+// and Receive methods return that error.
+//
+// The Close method of the returned connection returns the underlying
+// connection to the pool. Connections with a fatal error are closed instead of
+// returned to the pool. Use the Discard function to close a connection that is
+// known to be unusable, for example a connection left in an unknown state by
+// a MONITOR or SUBSCRIBE command.
 func (p *Pool) Get() Conn {
-	return &pooledConnection{p: p}
+	c, err := p.get(context.Background())
+	if err != nil {
+		return errorConnection{err}
+	}
+	return &pooledConnection{p: p, c: c}
+}
+
+// GetContext gets a connection using the provided context. If the pool is at
+// the MaxActive limit and Wait is true, then GetContext waits for a connection
+// to be returned to the pool or for the context to be done. If the context is
+// done first, then GetContext returns the context error. The context does not
+// apply to dialing a new connection or to the connection after GetContext
+// returns.
+//
+// If the function completes without error, then the application must close
+// the returned connection.
+func (p *Pool) GetContext(ctx context.Context) (Conn, error) {
+	c, err := p.get(ctx)
+	if err != nil {
+		return errorConnection{err}, err
+	}
+	return &pooledConnection{p: p, c: c}, nil
+}
+
+// ActiveCount returns the number of connections allocated by the pool,
+// including idle connections.
+func (p *Pool) ActiveCount() int {
+	p.mu.Lock()
+	active := p.active
+	p.mu.Unlock()
+	return active
+}
+
+// PoolStats contains pool statistics.
+type PoolStats struct {
+	// ActiveCount is the number of connections in the pool. The count includes
+	// idle connections and connections in use.
+	ActiveCount int
+	// IdleCount is the number of idle connections in the pool.
+	IdleCount int
+
+	// WaitCount is the total number of connections waited for.
+	WaitCount int64
+	// WaitDuration is the total time blocked waiting for a new connection.
+	WaitDuration time.Duration
+}
+
+// Stats returns the pool statistics.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	stats := PoolStats{
+		ActiveCount:  p.active,
+		IdleCount:    p.idle.Len(),
+		WaitCount:    p.waitCount,
+		WaitDuration: p.waitDuration,
+	}
+	p.mu.Unlock()
+	return stats
 }
 
 // Close releases the resources used by the pool.
 func (p *Pool) Close() error {
 	p.mu.Lock()
-	idle := p.idle
+	var idle []Conn
+	for e := p.idle.Front(); e != nil; e = e.Next() {
+		idle = append(idle, e.Value.(idleConn).c)
+	}
 	p.idle.Init()
 	p.closed = true
+	p.active -= len(idle)
+	if p.cond != nil {
+		p.cond.Broadcast()
+	}
 	p.mu.Unlock()
-	for e := idle.Front(); e != nil; e = e.Next() {
-		e.Value.(idleConn).c.Close()
+	for _, c := range idle {
+		c.Close()
 	}
 	return nil
 }
 
+// release decrements the active count and signals waiters. The caller must
+// hold p.mu during the call.
+func (p *Pool) release() {
+	p.active -= 1
+	if p.cond != nil {
+		p.cond.Signal()
+	}
+}
+
 // get prunes stale connections and returns a connection from the idle list or
 // creates a new connection.
-func (p *Pool) get() (Conn, error) {
-	p.mu.Lock()
-
-	if p.closed {
-		p.mu.Unlock()
-		return nil, errors.New("redigo: get on closed pool")
+func (p *Pool) get(ctx context.Context) (Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
+	p.mu.Lock()
+
 	// Prune stale connections.
 
 	if timeout := p.IdleTimeout; timeout > 0 {
@@ -146,41 +253,104 @@ func (p *Pool) get() (Conn, error) {
 				break
 			}
 			p.idle.Remove(e)
+			p.release()
 			p.mu.Unlock()
 			ic.c.Close()
 			p.mu.Lock()
 		}
 	}
 
-	// Get idle connection.
+	var watching chan struct{}
+	waited := false
+	for {
 
-	for i, n := 0, p.idle.Len(); i < n; i++ {
-		e := p.idle.Front()
-		if e == nil {
-			break
-		}
-		ic := e.Value.(idleConn)
-		p.idle.Remove(e)
-		test := p.TestOnBorrow
-		p.mu.Unlock()
-		if test != nil && test(ic.c, ic.t) != nil {
+		// Get idle connection.
+
+		for i, n := 0, p.idle.Len(); i < n; i++ {
+			e := p.idle.Front()
+			if e == nil {
+				break
+			}
+			ic := e.Value.(idleConn)
+			p.idle.Remove(e)
+			test := p.TestOnBorrow
+			p.mu.Unlock()
+			if test == nil || test(ic.c, ic.t) == nil {
+				return ic.c, nil
+			}
 			ic.c.Close()
-		} else {
-			return ic.c, nil
+			p.mu.Lock()
+			p.release()
 		}
-		p.mu.Lock()
-	}
 
-	// No idle connection, create new.
+		// Check for pool closed before dialing a new connection.
 
-	dial := p.Dial
-	p.mu.Unlock()
-	return dial()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, errors.New("redigo: get on closed pool")
+		}
+
+		// Dial new connection if under limit.
+
+		if p.MaxActive == 0 || p.active < p.MaxActive {
+			dial := p.Dial
+			p.active += 1
+			p.mu.Unlock()
+			c, err := dial()
+			if err != nil {
+				p.mu.Lock()
+				p.release()
+				p.mu.Unlock()
+				c = nil
+			}
+			return c, err
+		}
+
+		if !p.Wait {
+			p.mu.Unlock()
+			return nil, ErrPoolExhausted
+		}
+
+		if p.cond == nil {
+			p.cond = sync.NewCond(&p.mu)
+		}
+
+		if done := ctx.Done(); done != nil && watching == nil {
+			// Wake the waiters when the context is done. Waiters check the
+			// context error after waking.
+			watching = make(chan struct{})
+			defer close(watching)
+			go func(watching chan struct{}) {
+				select {
+				case <-done:
+					p.mu.Lock()
+					p.cond.Broadcast()
+					p.mu.Unlock()
+				case <-watching:
+				}
+			}(watching)
+		}
+
+		if err := ctx.Err(); err != nil {
+			// Pass a signal received by this waiter to another waiter.
+			p.cond.Signal()
+			p.mu.Unlock()
+			return nil, err
+		}
+		if !waited {
+			waited = true
+			p.waitCount++
+		}
+		start := time.Now()
+		p.cond.Wait()
+		p.waitDuration += time.Since(start)
+	}
 }
 
-func (p *Pool) put(c Conn) error {
+func (p *Pool) put(c Conn, forceClose bool) error {
+	err := c.Err()
 	p.mu.Lock()
-	if !p.closed {
+	if !p.closed && err == nil && !forceClose {
 		p.idle.PushFront(idleConn{t: nowFunc(), c: c})
 		if p.idle.Len() > p.MaxIdle {
 			c = p.idle.Remove(p.idle.Back()).(idleConn).c
@@ -188,71 +358,156 @@ func (p *Pool) put(c Conn) error {
 			c = nil
 		}
 	}
-	p.mu.Unlock()
-	if c != nil {
-		return c.Close()
+
+	if c == nil {
+		if p.cond != nil {
+			p.cond.Signal()
+		}
+		p.mu.Unlock()
+		return nil
 	}
-	return nil
+
+	p.release()
+	p.mu.Unlock()
+	return c.Close()
 }
 
 type pooledConnection struct {
-	c   Conn
-	err error
-	p   *Pool
+	p *Pool
+	c Conn
 }
 
-func (c *pooledConnection) get() error {
-	if c.err == nil && c.c == nil {
-		c.c, c.err = c.p.get()
+func (c *pooledConnection) Close() error {
+	cn := c.c
+	if _, ok := cn.(errorConnection); ok {
+		return nil
 	}
-	return c.err
+	c.c = errorConnection{errPoolClosed}
+	cn.Do("")
+	OnPush(cn, nil)
+	return c.p.put(cn, false)
 }
 
-func (c *pooledConnection) Close() (err error) {
-	if c.c != nil {
-		c.c.Do("")
-		if c.c.Err() != nil {
-			err = c.c.Close()
-		} else {
-			err = c.p.put(c.c)
-		}
-		c.c = nil
-		c.err = errPoolClosed
+// Discard closes the underlying connection and releases the connection's slot
+// in the pool.
+func (c *pooledConnection) Discard() error {
+	cn := c.c
+	if _, ok := cn.(errorConnection); ok {
+		return nil
 	}
-	return err
+	c.c = errorConnection{errPoolClosed}
+	return c.p.put(cn, true)
 }
 
 func (c *pooledConnection) Err() error {
-	if err := c.get(); err != nil {
-		return err
-	}
 	return c.c.Err()
 }
 
 func (c *pooledConnection) Do(commandName string, args ...interface{}) (reply interface{}, err error) {
-	if err := c.get(); err != nil {
-		return nil, err
-	}
 	return c.c.Do(commandName, args...)
 }
 
+func (c *pooledConnection) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (reply interface{}, err error) {
+	return DoWithTimeout(c.c, timeout, commandName, args...)
+}
+
+func (c *pooledConnection) DoContext(ctx context.Context, commandName string, args ...interface{}) (reply interface{}, err error) {
+	return DoContext(c.c, ctx, commandName, args...)
+}
+
 func (c *pooledConnection) Send(commandName string, args ...interface{}) error {
-	if err := c.get(); err != nil {
-		return err
-	}
 	return c.c.Send(commandName, args...)
 }
 
 func (c *pooledConnection) Flush() error {
-	if err := c.get(); err != nil {
-		return err
-	}
 	return c.c.Flush()
 }
 
 func (c *pooledConnection) Receive() (reply interface{}, err error) {
-	if err := c.get(); err != nil {
-		return nil, err
-	}
 	return c.c.Receive()
 }
+
+func (c *pooledConnection) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return ReceiveWithTimeout(c.c, timeout)
+}
+
+func (c *pooledConnection) ReceiveReader() (io.Reader, error) {
+	return ReceiveReader(c.c)
+}
+
+func (c *pooledConnection) CopyReply(w io.Writer) error {
+	return CopyReply(c.c, w)
+}
+
+func (c *pooledConnection) NetConn() net.Conn {
+	nc, _ := NetConn(c.c)
+	return nc
+}
+
+func (c *pooledConnection) SendInline(parts ...string) error {
+	return SendInline(c.c, parts...)
+}
+
+func (c *pooledConnection) OnPush(f func(push []interface{})) {
+	OnPush(c.c, f)
+}
+
+func (c *pooledConnection) DoRaw(serialized []byte) (interface{}, error) {
+	return DoRaw(c.c, serialized)
+}
+
+func (c *pooledConnection) Ping() error {
+	return Ping(c.c)
+}
+
+func (c *pooledConnection) ReceiveWithKind() (byte, interface{}, error) {
+	return ReceiveWithKind(c.c)
+}
+
+// errorConnection is returned by Get when the pool cannot provide a
+// connection and takes the place of a pooled connection after Close.
+type errorConnection struct{ err error }
+
+func (ec errorConnection) Close() error                      { return ec.err }
+func (ec errorConnection) Err() error                        { return ec.err }
+func (ec errorConnection) Send(string, ...interface{}) error { return ec.err }
+func (ec errorConnection) Flush() error                      { return ec.err }
+func (ec errorConnection) Receive() (interface{}, error)     { return nil, ec.err }
+
+func (ec errorConnection) Do(string, ...interface{}) (interface{}, error) {
+	return nil, ec.err
+}
+
+func (ec errorConnection) SendInline(parts ...string) error { return ec.err }
+
+func (ec errorConnection) DoRaw(serialized []byte) (interface{}, error) {
+	return nil, ec.err
+}
+
+func (ec errorConnection) Ping() error { return ec.err }
+
+func (ec errorConnection) ReceiveWithKind() (byte, interface{}, error) {
+	return 0, nil, ec.err
+}
+
+func (ec errorConnection) Discard() error { return nil }
+
+func (ec errorConnection) OnPush(f func(push []interface{})) {}
+
+func (ec errorConnection) NetConn() net.Conn { return nil }
+
+func (ec errorConnection) ReceiveReader() (io.Reader, error) { return nil, ec.err }
+
+func (ec errorConnection) CopyReply(io.Writer) error { return ec.err }
+
+func (ec errorConnection) DoContext(context.Context, string, ...interface{}) (interface{}, error) {
+	return nil, ec.err
+}
+
+func (ec errorConnection) ReceiveWithTimeout(time.Duration) (interface{}, error) {
+	return nil, ec.err
+}
+
+func (ec errorConnection) DoWithTimeout(time.Duration, string, ...interface{}) (interface{}, error) {
+	return nil, ec.err
+}
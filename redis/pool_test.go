@@ -15,7 +15,13 @@
 package redis
 
 import (
+	"bufio"
+	"context"
 	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -67,6 +73,37 @@ func TestPoolReuse(t *testing.T) {
 	}
 }
 
+func TestPoolClearsOnPush(t *testing.T) {
+	const push = ">2\r\n$10\r\ninvalidate\r\n*1\r\n$3\r\nfoo\r\n"
+	p := &Pool{
+		MaxIdle: 1,
+		Dial: func() (Conn, error) {
+			return NewConnBufio(bufio.ReadWriter{
+				Reader: bufio.NewReader(strings.NewReader(push)),
+				Writer: bufio.NewWriter(ioutil.Discard),
+			}), nil
+		},
+	}
+
+	c := p.Get()
+	called := false
+	if err := OnPush(c, func([]interface{}) { called = true }); err != nil {
+		t.Fatalf("OnPush returned %v", err)
+	}
+	c.Close()
+
+	// The next borrower receives the push message from Receive.
+	c = p.Get()
+	defer c.Close()
+	expected := []interface{}{[]byte("invalidate"), []interface{}{[]byte("foo")}}
+	if v, err := c.Receive(); err != nil || !reflect.DeepEqual(v, expected) {
+		t.Errorf("Receive returned %v, %v, want %v, nil", v, err, expected)
+	}
+	if called {
+		t.Error("handler set before the connection was returned to the pool was called")
+	}
+}
+
 func TestPoolMaxIdle(t *testing.T) {
 	var open, dialed int
 	p := &Pool{
@@ -113,6 +150,51 @@ func TestPoolError(t *testing.T) {
 	}
 }
 
+func TestPoolDiscard(t *testing.T) {
+	var open, dialed int
+	p := &Pool{
+		MaxIdle:   2,
+		MaxActive: 2,
+		Dial:      func() (Conn, error) { open += 1; dialed += 1; return &fakeConn{open: &open}, nil },
+	}
+
+	c1 := p.Get()
+	c1.Do("PING")
+	c2 := p.Get()
+	c2.Do("PING")
+	if err := Discard(c1); err != nil {
+		t.Fatalf("Discard returned %v", err)
+	}
+	if err := Discard(c1); err != nil {
+		t.Errorf("second Discard returned %v", err)
+	}
+	c2.Close()
+
+	if open != 1 || dialed != 2 {
+		t.Errorf("want open=1, got %d; want dialed=2, got %d", open, dialed)
+	}
+	if n := p.ActiveCount(); n != 1 {
+		t.Errorf("ActiveCount() = %d, want 1", n)
+	}
+	if _, err := c1.Do("PING"); err == nil {
+		t.Errorf("Do on discarded connection did not return error")
+	}
+
+	c3 := p.Get()
+	if err := Discard(c3); err != nil {
+		t.Fatalf("Discard returned %v", err)
+	}
+	if open != 0 || dialed != 2 {
+		t.Errorf("want open=0, got %d; want dialed=2, got %d", open, dialed)
+	}
+
+	nc := &fakeConn{open: &open}
+	open += 1
+	if err := Discard(nc); err != nil || open != 0 {
+		t.Errorf("Discard of unpooled connection returned %v with open=%d, want nil with open=0", err, open)
+	}
+}
+
 func TestPoolClose(t *testing.T) {
 	var open, dialed int
 	p := &Pool{
@@ -184,6 +266,21 @@ func TestPoolTimeout(t *testing.T) {
 	if open != 1 || dialed != 2 {
 		t.Errorf("want open=1, got %d; want dialed=2, got %d", open, dialed)
 	}
+
+	if n := p.ActiveCount(); n != 1 {
+		t.Errorf("want active=1, got %d", n)
+	}
+
+	// A connection returned just before the timeout is reused.
+	now = now.Add(p.IdleTimeout - time.Second)
+
+	c = p.Get()
+	c.Do("PING")
+	c.Close()
+
+	if open != 1 || dialed != 2 {
+		t.Errorf("want open=1, got %d; want dialed=2, got %d", open, dialed)
+	}
 }
 
 func TestBorrowCheck(t *testing.T) {
@@ -203,3 +300,193 @@ func TestBorrowCheck(t *testing.T) {
 		t.Errorf("want open=1, got %d; want dialed=10, got %d", open, dialed)
 	}
 }
+
+func TestBorrowCheckIdleThreshold(t *testing.T) {
+	var open, dialed, tested int
+	p := &Pool{
+		MaxIdle: 2,
+		Dial:    func() (Conn, error) { open += 1; dialed += 1; return &fakeConn{open: &open}, nil },
+		TestOnBorrow: func(c Conn, t time.Time) error {
+			if nowFunc().Sub(t) < time.Minute {
+				return nil
+			}
+			tested += 1
+			return Error("BLAH")
+		},
+	}
+
+	now := time.Now()
+	nowFunc = func() time.Time { return now }
+	defer func() { nowFunc = time.Now }()
+
+	c := p.Get()
+	c.Do("PING")
+	c.Close()
+
+	c = p.Get()
+	c.Do("PING")
+	c.Close()
+
+	if tested != 0 || dialed != 1 {
+		t.Errorf("want tested=0, got %d; want dialed=1, got %d", tested, dialed)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	c = p.Get()
+	c.Do("PING")
+	c.Close()
+
+	if tested != 1 || open != 1 || dialed != 2 {
+		t.Errorf("want tested=1, got %d; want open=1, got %d; want dialed=2, got %d", tested, open, dialed)
+	}
+}
+
+func TestPoolMaxActive(t *testing.T) {
+	var open, dialed int
+	p := &Pool{
+		MaxIdle:   2,
+		MaxActive: 2,
+		Dial:      func() (Conn, error) { open += 1; dialed += 1; return &fakeConn{open: &open}, nil },
+	}
+	defer p.Close()
+
+	c1 := p.Get()
+	c1.Do("PING")
+	c2 := p.Get()
+	c2.Do("PING")
+
+	c3 := p.Get()
+	if _, err := c3.Do("PING"); err != ErrPoolExhausted {
+		t.Errorf("expected pool exhausted, got %v", err)
+	}
+	c3.Close()
+
+	if n := p.ActiveCount(); n != 2 {
+		t.Errorf("want active=2, got %d", n)
+	}
+
+	c2.Close()
+	c3 = p.Get()
+	if _, err := c3.Do("PING"); err != nil {
+		t.Errorf("expected good connection, got %v", err)
+	}
+	c3.Close()
+
+	c1.Do("ERR", io.EOF)
+	c1.Close()
+
+	if n := p.ActiveCount(); n != 1 {
+		t.Errorf("want active=1, got %d", n)
+	}
+	if open != 1 || dialed != 2 {
+		t.Errorf("want open=1, got %d; want dialed=2, got %d", open, dialed)
+	}
+}
+
+func TestPoolWait(t *testing.T) {
+	var mu sync.Mutex
+	var open int
+	p := &Pool{
+		MaxIdle:   1,
+		MaxActive: 1,
+		Wait:      true,
+		Dial: func() (Conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			open += 1
+			return &fakeConn{open: &open}, nil
+		},
+	}
+	defer p.Close()
+
+	c1 := p.Get()
+	errs := make(chan error)
+	go func() {
+		c2 := p.Get()
+		_, err := c2.Do("PING")
+		c2.Close()
+		errs <- err
+	}()
+
+	select {
+	case err := <-errs:
+		t.Fatalf("Get returned before connection was closed, err=%v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	c1.Close()
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("expected good connection, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Get did not return after connection was closed")
+	}
+
+	if n := p.ActiveCount(); n != 1 {
+		t.Errorf("want active=1, got %d", n)
+	}
+
+	stats := p.Stats()
+	if stats.ActiveCount != 1 || stats.IdleCount != 1 || stats.WaitCount != 1 {
+		t.Errorf("Stats() = %+v, want ActiveCount=1, IdleCount=1, WaitCount=1", stats)
+	}
+	if stats.WaitDuration < 10*time.Millisecond {
+		t.Errorf("Stats().WaitDuration = %v, want at least 10ms", stats.WaitDuration)
+	}
+}
+
+func TestPoolGetContext(t *testing.T) {
+	var mu sync.Mutex
+	var open int
+	p := &Pool{
+		MaxIdle:   1,
+		MaxActive: 1,
+		Wait:      true,
+		Dial: func() (Conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			open += 1
+			return &fakeConn{open: &open}, nil
+		},
+	}
+	defer p.Close()
+
+	c1, err := p.GetContext(context.Background())
+	if err != nil {
+		t.Fatalf("GetContext returned %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	c2, err := p.GetContext(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("GetContext returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	if c2.Err() != err {
+		t.Errorf("connection Err() = %v, want %v", c2.Err(), err)
+	}
+	if n := p.ActiveCount(); n != 1 {
+		t.Errorf("want active=1 after cancelled wait, got %d", n)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.GetContext(ctx); err != context.Canceled {
+		t.Errorf("GetContext with cancelled context returned %v, want %v", err, context.Canceled)
+	}
+
+	c1.Close()
+
+	c3, err := p.GetContext(context.Background())
+	if err != nil {
+		t.Fatalf("GetContext returned %v after connection was closed", err)
+	}
+	c3.Close()
+	if n := p.ActiveCount(); n != 1 {
+		t.Errorf("want active=1, got %d", n)
+	}
+}
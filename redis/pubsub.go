@@ -16,9 +16,10 @@ package redis
 
 import (
 	"errors"
+	"time"
 )
 
-// Subscribe represents a subscribe or unsubscribe notification.
+// Subscription represents a subscribe or unsubscribe notification.
 type Subscription struct {
 
 	// Kind is "subscribe", "unsubscribe", "psubscribe" or "punsubscribe"
@@ -27,7 +28,8 @@ type Subscription struct {
 	// The channel that was changed.
 	Channel string
 
-	// The current number of subscriptions for connection.
+	// The current number of channel and pattern subscriptions for the
+	// connection after the change.
 	Count int
 }
 
@@ -54,6 +56,13 @@ type PMessage struct {
 	Data []byte
 }
 
+// Pong represents a pubsub pong notification.
+type Pong struct {
+
+	// The data sent with the PING command.
+	Data string
+}
+
 // PubSubConn wraps a Conn with convenience methods for subscribers.
 type PubSubConn struct {
 	Conn Conn
@@ -64,7 +73,9 @@ func (c PubSubConn) Close() error {
 	return c.Conn.Close()
 }
 
-// Subscribe subscribes the connection to the specified channels.
+// Subscribe subscribes the connection to the specified channels. The server
+// acknowledges each channel with a separate notification. Call Receive once
+// for each channel to receive the corresponding Subscription.
 func (c PubSubConn) Subscribe(channel ...interface{}) error {
 	c.Conn.Send("SUBSCRIBE", channel...)
 	return c.Conn.Flush()
@@ -90,11 +101,45 @@ func (c PubSubConn) PUnsubscribe(channel ...interface{}) error {
 	return c.Conn.Flush()
 }
 
-// Receive returns a pushed message as a Subscription, Message, PMessage or
-// error. The return value is intended to be used directly in a type switch as
+// Ping sends a PING to the server with the specified data. The server replies
+// with a Pong notification. Use Ping to check the health of a connection that
+// is subscribed to channels.
+func (c PubSubConn) Ping(data string) error {
+	c.Conn.Send("PING", data)
+	return c.Conn.Flush()
+}
+
+// Receive returns a pushed message as a Subscription, Message, PMessage, Pong
+// or error. The return value is intended to be used directly in a type switch as
 // illustrated in the PubSubConn example.
 func (c PubSubConn) Receive() interface{} {
-	reply, err := Values(c.Conn.Receive())
+	return c.receiveInternal(c.Conn.Receive())
+}
+
+// ReceiveWithTimeout is like Receive, but it allows the application to
+// override the connection's default read timeout. If no message arrives before
+// the timeout expires, then ReceiveWithTimeout returns a net.Error with
+// Timeout() true and the connection remains usable:
+//
+//  for {
+//      switch v := psc.ReceiveWithTimeout(time.Second).(type) {
+//      case redis.Message:
+//          // process message
+//      case net.Error:
+//          if !v.Timeout() {
+//              return v
+//          }
+//          // check for shutdown
+//      case error:
+//          return v
+//      }
+//  }
+func (c PubSubConn) ReceiveWithTimeout(timeout time.Duration) interface{} {
+	return c.receiveInternal(ReceiveWithTimeout(c.Conn, timeout))
+}
+
+func (c PubSubConn) receiveInternal(replyArg interface{}, errArg error) interface{} {
+	reply, err := Values(replyArg, errArg)
 	if err != nil {
 		return err
 	}
@@ -118,6 +163,12 @@ func (c PubSubConn) Receive() interface{} {
 			return err
 		}
 		return pm
+	case "pong":
+		var p Pong
+		if _, err := Scan(reply, &p.Data); err != nil {
+			return err
+		}
+		return p
 	case "subscribe", "psubscribe", "unsubscribe", "punsubscribe":
 		s := Subscription{Kind: kind}
 		if _, err := Scan(reply, &s.Channel, &s.Count); err != nil {
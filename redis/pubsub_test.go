@@ -15,10 +15,13 @@
 package redis_test
 
 import (
+	"bufio"
 	"fmt"
 	"github.com/garyburd/redigo/redis"
+	"io/ioutil"
 	"net"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -135,4 +138,69 @@ func TestPushed(t *testing.T) {
 
 	pc.Do("PUBLISH", "c1", "hello")
 	expectPushed(t, c, "PUBLISH c1 hello", redis.Message{"c1", []byte("hello")})
+
+	c.Ping("hello")
+	expectPushed(t, c, "Ping(hello)", redis.Pong{"hello"})
+}
+
+func TestPubSubReceive(t *testing.T) {
+	replies := "*3\r\n$9\r\nsubscribe\r\n$2\r\nc1\r\n:1\r\n" +
+		"*3\r\n$7\r\nmessage\r\n$2\r\nc1\r\n$5\r\nhello\r\n" +
+		"*4\r\n$8\r\npmessage\r\n$2\r\np*\r\n$2\r\npc\r\n$5\r\nworld\r\n" +
+		"*2\r\n$4\r\npong\r\n$4\r\nping\r\n" +
+		"*3\r\n$7\r\nunknown\r\n$2\r\nc1\r\n:1\r\n"
+	rw := bufio.ReadWriter{
+		Reader: bufio.NewReader(strings.NewReader(replies)),
+		Writer: bufio.NewWriter(nil),
+	}
+	c := redis.PubSubConn{redis.NewConnBufio(rw)}
+
+	expectPushed(t, c, "subscribe", redis.Subscription{"subscribe", "c1", 1})
+	expectPushed(t, c, "message", redis.Message{"c1", []byte("hello")})
+	expectPushed(t, c, "pmessage", redis.PMessage{"p*", "pc", []byte("world")})
+	expectPushed(t, c, "pong", redis.Pong{"ping"})
+	if _, ok := c.Receive().(error); !ok {
+		t.Errorf("unknown notification did not return error")
+	}
+}
+
+func TestPubSubSubscriptionCount(t *testing.T) {
+	replies := "*3\r\n$9\r\nsubscribe\r\n$2\r\nc1\r\n:1\r\n" +
+		"*3\r\n$9\r\nsubscribe\r\n$2\r\nc2\r\n:2\r\n" +
+		"*3\r\n$10\r\npsubscribe\r\n$2\r\np*\r\n:3\r\n" +
+		"*3\r\n$11\r\nunsubscribe\r\n$2\r\nc1\r\n:2\r\n"
+	rw := bufio.ReadWriter{
+		Reader: bufio.NewReader(strings.NewReader(replies)),
+		Writer: bufio.NewWriter(ioutil.Discard),
+	}
+	c := redis.PubSubConn{redis.NewConnBufio(rw)}
+
+	c.Subscribe("c1", "c2")
+	expectPushed(t, c, "Subscribe(c1, c2) c1", redis.Subscription{"subscribe", "c1", 1})
+	expectPushed(t, c, "Subscribe(c1, c2) c2", redis.Subscription{"subscribe", "c2", 2})
+	c.PSubscribe("p*")
+	expectPushed(t, c, "PSubscribe(p*)", redis.Subscription{"psubscribe", "p*", 3})
+	c.Unsubscribe("c1")
+	expectPushed(t, c, "Unsubscribe(c1)", redis.Subscription{"unsubscribe", "c1", 2})
+}
+
+func TestPubSubReceiveWithTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	c := redis.PubSubConn{redis.NewConn(client, 0, 0)}
+	defer c.Close()
+
+	v := c.ReceiveWithTimeout(10 * time.Millisecond)
+	if err, ok := v.(net.Error); !ok || !err.Timeout() {
+		t.Fatalf("ReceiveWithTimeout returned %v, want timeout error", v)
+	}
+	if err := c.Conn.Err(); err != nil {
+		t.Fatalf("Err() after timeout = %v, want nil", err)
+	}
+
+	go server.Write([]byte("*3\r\n$7\r\nmessage\r\n$2\r\nc1\r\n$5\r\nhello\r\n"))
+	expected := redis.Message{"c1", []byte("hello")}
+	if v := c.ReceiveWithTimeout(time.Second); !reflect.DeepEqual(v, expected) {
+		t.Errorf("ReceiveWithTimeout returned %v, want %v", v, expected)
+	}
 }
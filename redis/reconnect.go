@@ -0,0 +1,383 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+var (
+	errReconnectClosed    = errors.New("redigo: use of closed connection")
+	errReconnectNotDialed = errors.New("redigo: no command sent on connection")
+)
+
+// NewReconnectConn returns a connection that dials a new underlying connection
+// with the dial function when the current underlying connection fails. The
+// first underlying connection is dialed on first use.
+//
+// If a call to Do with a read-only command such as GET or HGETALL fails with
+// a connection error, then Do dials a new connection and executes the command
+// once more. Other commands are retried only when named with the
+// ReconnectRetryCommands option. The command is not retried when replies to
+// commands sent with Send are pending or when the connection is in a MULTI
+// transaction, because that state cannot be restored on a new connection. In
+// these cases, the error is returned to the application and the next call to
+// Do or Send dials a new connection.
+//
+// Flush and Receive do not dial a new connection because the replies to
+// commands sent on a failed connection are lost. After the connection fails,
+// Flush and Receive return the connection error until the next call to Do or
+// Send.
+//
+// The Err method returns a non-nil value only after the connection is
+// closed.
+func NewReconnectConn(dial func() (Conn, error), options ...ReconnectOption) Conn {
+	rc := &reconnectConn{dial: dial, retry: readOnlyCommands}
+	for _, option := range options {
+		option.f(rc)
+	}
+	return rc
+}
+
+// ReconnectOption specifies an option for NewReconnectConn.
+type ReconnectOption struct {
+	f func(*reconnectConn)
+}
+
+// ReconnectRetryCommands specifies commands in addition to the read-only
+// commands that Do retries on a new connection. A retried command is executed
+// twice by the server if the connection failed after the server received the
+// command. Specify only commands that are safe to execute twice. PUBLISH and
+// INCR, for example, are not: a message may be delivered twice and a counter
+// incremented twice.
+func ReconnectRetryCommands(names ...string) ReconnectOption {
+	return ReconnectOption{func(rc *reconnectConn) {
+		retry := make(map[string]bool, len(rc.retry)+len(names))
+		for name := range rc.retry {
+			retry[name] = true
+		}
+		for _, name := range names {
+			retry[strings.ToUpper(name)] = true
+		}
+		rc.retry = retry
+	}}
+}
+
+// readOnlyCommands is the set of commands retried by default.
+var readOnlyCommands = map[string]bool{
+	"BITCOUNT":         true,
+	"DBSIZE":           true,
+	"ECHO":             true,
+	"EXISTS":           true,
+	"GET":              true,
+	"GETBIT":           true,
+	"GETRANGE":         true,
+	"HEXISTS":          true,
+	"HGET":             true,
+	"HGETALL":          true,
+	"HKEYS":            true,
+	"HLEN":             true,
+	"HMGET":            true,
+	"HSTRLEN":          true,
+	"HVALS":            true,
+	"LINDEX":           true,
+	"LLEN":             true,
+	"LRANGE":           true,
+	"MGET":             true,
+	"PING":             true,
+	"PTTL":             true,
+	"SCARD":            true,
+	"SISMEMBER":        true,
+	"SMEMBERS":         true,
+	"STRLEN":           true,
+	"TTL":              true,
+	"TYPE":             true,
+	"ZCARD":            true,
+	"ZCOUNT":           true,
+	"ZRANGE":           true,
+	"ZRANGEBYSCORE":    true,
+	"ZRANK":            true,
+	"ZREVRANGE":        true,
+	"ZREVRANGEBYSCORE": true,
+	"ZREVRANK":         true,
+	"ZSCORE":           true,
+}
+
+type reconnectConn struct {
+	dial    func() (Conn, error)
+	c       Conn
+	closed  bool
+	pending int
+	multi   bool
+	retry   map[string]bool
+	onPush  func(push []interface{})
+}
+
+// conn returns a usable underlying connection, dialing a new connection if
+// the current one has failed.
+func (rc *reconnectConn) conn() (Conn, error) {
+	if rc.closed {
+		return nil, errReconnectClosed
+	}
+	if rc.c != nil {
+		if rc.c.Err() == nil {
+			return rc.c, nil
+		}
+		rc.c.Close()
+		rc.c = nil
+	}
+	c, err := rc.dial()
+	if err != nil {
+		return nil, err
+	}
+	if rc.onPush != nil {
+		OnPush(c, rc.onPush)
+	}
+	rc.c = c
+	return c, nil
+}
+
+// current returns the current underlying connection without dialing. Replies
+// to commands sent on a failed connection cannot be received from a new
+// connection.
+func (rc *reconnectConn) current() (Conn, error) {
+	if rc.closed {
+		return nil, errReconnectClosed
+	}
+	if rc.c == nil {
+		return nil, errReconnectNotDialed
+	}
+	if err := rc.c.Err(); err != nil {
+		return nil, err
+	}
+	return rc.c, nil
+}
+
+// reset clears the pipeline and transaction state after the error from a
+// failed connection is returned to the application.
+func (rc *reconnectConn) reset(c Conn, err error) {
+	if err != nil && c.Err() != nil {
+		rc.pending = 0
+		rc.multi = false
+	}
+}
+
+func (rc *reconnectConn) track(cmd string) {
+	switch strings.ToUpper(cmd) {
+	case "MULTI":
+		rc.multi = true
+	case "EXEC", "DISCARD":
+		rc.multi = false
+	}
+}
+
+func (rc *reconnectConn) Close() error {
+	if rc.closed {
+		return nil
+	}
+	rc.closed = true
+	if rc.c == nil {
+		return nil
+	}
+	return rc.c.Close()
+}
+
+func (rc *reconnectConn) Discard() error {
+	if rc.closed {
+		return nil
+	}
+	rc.closed = true
+	if rc.c == nil {
+		return nil
+	}
+	return Discard(rc.c)
+}
+
+func (rc *reconnectConn) Err() error {
+	if rc.closed {
+		return errReconnectClosed
+	}
+	return nil
+}
+
+func (rc *reconnectConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return rc.do(cmd, func(c Conn) (interface{}, error) {
+		return c.Do(cmd, args...)
+	})
+}
+
+func (rc *reconnectConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	return rc.do(cmd, func(c Conn) (interface{}, error) {
+		return DoWithTimeout(c, timeout, cmd, args...)
+	})
+}
+
+func (rc *reconnectConn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	return rc.do(cmd, func(c Conn) (interface{}, error) {
+		return DoContext(c, ctx, cmd, args...)
+	})
+}
+
+// DoRaw is not retried because the command name is not known.
+func (rc *reconnectConn) DoRaw(serialized []byte) (interface{}, error) {
+	return rc.do("", func(c Conn) (interface{}, error) {
+		return DoRaw(c, serialized)
+	})
+}
+
+func (rc *reconnectConn) Ping() error {
+	_, err := rc.do("PING", func(c Conn) (interface{}, error) {
+		return nil, Ping(c)
+	})
+	return err
+}
+
+// do executes cmd with f and executes the command once more on a new
+// connection if the command is retryable and the connection failed.
+func (rc *reconnectConn) do(cmd string, f func(c Conn) (interface{}, error)) (interface{}, error) {
+	c, err := rc.conn()
+	if err != nil {
+		return nil, err
+	}
+	retry := rc.retry[strings.ToUpper(cmd)] && rc.pending == 0 && !rc.multi
+	reply, err := f(c)
+	if err != nil && c.Err() != nil && retry {
+		if c, err = rc.conn(); err != nil {
+			return nil, err
+		}
+		reply, err = f(c)
+	}
+	rc.pending = 0
+	// The server executed the command when the reply is a Redis error.
+	if _, ok := err.(Error); err == nil || ok {
+		rc.track(cmd)
+	}
+	rc.reset(c, err)
+	return reply, err
+}
+
+func (rc *reconnectConn) Send(cmd string, args ...interface{}) error {
+	return rc.send(cmd, func(c Conn) error {
+		return c.Send(cmd, args...)
+	})
+}
+
+func (rc *reconnectConn) SendInline(parts ...string) error {
+	cmd := ""
+	if len(parts) > 0 {
+		cmd = parts[0]
+	}
+	return rc.send(cmd, func(c Conn) error {
+		return SendInline(c, parts...)
+	})
+}
+
+func (rc *reconnectConn) send(cmd string, f func(c Conn) error) error {
+	c, err := rc.conn()
+	if err != nil {
+		return err
+	}
+	if err := f(c); err != nil {
+		rc.reset(c, err)
+		return err
+	}
+	rc.pending++
+	rc.track(cmd)
+	return nil
+}
+
+func (rc *reconnectConn) Flush() error {
+	c, err := rc.current()
+	if err != nil {
+		return err
+	}
+	err = c.Flush()
+	rc.reset(c, err)
+	return err
+}
+
+// receive receives a reply with f from the current connection.
+func (rc *reconnectConn) receive(f func(c Conn) error) error {
+	c, err := rc.current()
+	if err != nil {
+		return err
+	}
+	if rc.pending > 0 {
+		rc.pending--
+	}
+	err = f(c)
+	rc.reset(c, err)
+	return err
+}
+
+func (rc *reconnectConn) Receive() (reply interface{}, err error) {
+	err = rc.receive(func(c Conn) error {
+		reply, err = c.Receive()
+		return err
+	})
+	return reply, err
+}
+
+func (rc *reconnectConn) ReceiveWithTimeout(timeout time.Duration) (reply interface{}, err error) {
+	err = rc.receive(func(c Conn) error {
+		reply, err = ReceiveWithTimeout(c, timeout)
+		return err
+	})
+	return reply, err
+}
+
+func (rc *reconnectConn) ReceiveWithKind() (kind byte, reply interface{}, err error) {
+	err = rc.receive(func(c Conn) error {
+		kind, reply, err = ReceiveWithKind(c)
+		return err
+	})
+	return kind, reply, err
+}
+
+func (rc *reconnectConn) ReceiveReader() (r io.Reader, err error) {
+	err = rc.receive(func(c Conn) error {
+		r, err = ReceiveReader(c)
+		return err
+	})
+	return r, err
+}
+
+func (rc *reconnectConn) CopyReply(w io.Writer) error {
+	return rc.receive(func(c Conn) error {
+		return CopyReply(c, w)
+	})
+}
+
+// OnPush sets the handler on the current connection and on connections dialed
+// later.
+func (rc *reconnectConn) OnPush(f func(push []interface{})) {
+	rc.onPush = f
+	if rc.c != nil {
+		OnPush(rc.c, f)
+	}
+}
+
+func (rc *reconnectConn) NetConn() net.Conn {
+	if rc.c == nil {
+		return nil
+	}
+	nc, _ := NetConn(rc.c)
+	return nc
+}
@@ -0,0 +1,280 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// failingServer returns a server that sends an invalid reply to the first
+// command named fail and handles all other commands normally.
+func failingServer(t *testing.T, fail string) *fakeServer {
+	var mu sync.Mutex
+	failed := false
+	return newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		if args[0] == fail && !failed {
+			failed = true
+			return "@\r\n"
+		}
+		if args[0] == "GET" {
+			return "$3\r\nbar\r\n"
+		}
+		return "+OK\r\n"
+	})
+}
+
+func TestReconnectConn(t *testing.T) {
+	s := failingServer(t, "GET")
+	defer s.close()
+
+	dials := 0
+	c := redis.NewReconnectConn(func() (redis.Conn, error) {
+		dials++
+		return redis.Dial("tcp", s.addr())
+	})
+	defer c.Close()
+
+	v, err := redis.String(c.Do("GET", "foo"))
+	if err != nil {
+		t.Fatalf("Do(GET) returned %v", err)
+	}
+	if v != "bar" {
+		t.Errorf("Do(GET) = %q, want %q", v, "bar")
+	}
+	if dials != 2 {
+		t.Errorf("dials = %d, want 2", dials)
+	}
+	if err := c.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+
+	c.Close()
+	if _, err := c.Do("GET", "foo"); err == nil {
+		t.Error("Do after Close returned nil error")
+	}
+	if err := c.Err(); err == nil {
+		t.Error("Err() after Close returned nil")
+	}
+}
+
+func TestReconnectConnNoRetry(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(c redis.Conn) error
+	}{
+		{
+			"pending",
+			func(c redis.Conn) error {
+				c.Send("SET", "foo", "bar")
+				_, err := c.Do("GET", "foo")
+				return err
+			},
+		},
+		{
+			"multi",
+			func(c redis.Conn) error {
+				if _, err := c.Do("MULTI"); err != nil {
+					return err
+				}
+				_, err := c.Do("GET", "foo")
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		s := failingServer(t, "GET")
+		dials := 0
+		c := redis.NewReconnectConn(func() (redis.Conn, error) {
+			dials++
+			return redis.Dial("tcp", s.addr())
+		})
+		if err := tt.run(c); err == nil {
+			t.Errorf("%s: command returned nil error, want connection error", tt.name)
+		}
+		if dials != 1 {
+			t.Errorf("%s: dials = %d, want 1", tt.name, dials)
+		}
+		if _, err := c.Do("GET", "foo"); err != nil {
+			t.Errorf("%s: Do(GET) after failure returned %v", tt.name, err)
+		}
+		if dials != 2 {
+			t.Errorf("%s: dials after failure = %d, want 2", tt.name, dials)
+		}
+		c.Close()
+		s.close()
+	}
+}
+
+func TestReconnectConnRetryCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []redis.ReconnectOption
+		dials   int
+	}{
+		{"default", nil, 1},
+		{"retry SET", []redis.ReconnectOption{redis.ReconnectRetryCommands("set")}, 2},
+	}
+
+	for _, tt := range tests {
+		s := failingServer(t, "SET")
+		dials := 0
+		c := redis.NewReconnectConn(func() (redis.Conn, error) {
+			dials++
+			return redis.Dial("tcp", s.addr())
+		}, tt.options...)
+		_, err := c.Do("SET", "foo", "bar")
+		if tt.dials == 1 && err == nil {
+			t.Errorf("%s: Do(SET) returned nil error, want connection error", tt.name)
+		}
+		if tt.dials == 2 && err != nil {
+			t.Errorf("%s: Do(SET) returned %v", tt.name, err)
+		}
+		if dials != tt.dials {
+			t.Errorf("%s: dials = %d, want %d", tt.name, dials, tt.dials)
+		}
+		c.Close()
+		s.close()
+	}
+}
+
+func TestReconnectConnExecError(t *testing.T) {
+	var mu sync.Mutex
+	failed := false
+	s := newFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch args[0] {
+		case "EXEC":
+			return "-EXECABORT Transaction discarded because of previous errors.\r\n"
+		case "GET":
+			if !failed {
+				failed = true
+				return "@\r\n"
+			}
+			return "$3\r\nbar\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	dials := 0
+	c := redis.NewReconnectConn(func() (redis.Conn, error) {
+		dials++
+		return redis.Dial("tcp", s.addr())
+	})
+	defer c.Close()
+
+	if _, err := c.Do("MULTI"); err != nil {
+		t.Fatalf("Do(MULTI) returned %v", err)
+	}
+	if _, err := c.Do("EXEC"); err == nil {
+		t.Fatal("Do(EXEC) returned nil error, want EXECABORT")
+	}
+
+	// The transaction ended with the error reply to EXEC, so GET is retried.
+	if v, err := redis.String(c.Do("GET", "foo")); err != nil || v != "bar" {
+		t.Errorf("Do(GET) returned %q, %v, want bar, nil", v, err)
+	}
+	if dials != 2 {
+		t.Errorf("dials = %d, want 2", dials)
+	}
+}
+
+func TestReconnectConnOptionalMethods(t *testing.T) {
+	tests := []struct {
+		name string
+		do   func(c redis.Conn) (interface{}, error)
+	}{
+		{"DoWithTimeout", func(c redis.Conn) (interface{}, error) {
+			return redis.DoWithTimeout(c, time.Second, "GET", "foo")
+		}},
+		{"DoContext", func(c redis.Conn) (interface{}, error) {
+			return redis.DoContext(c, context.Background(), "GET", "foo")
+		}},
+	}
+
+	for _, tt := range tests {
+		s := failingServer(t, "GET")
+		dials := 0
+		c := redis.NewReconnectConn(func() (redis.Conn, error) {
+			dials++
+			return redis.Dial("tcp", s.addr())
+		})
+		if v, err := redis.String(tt.do(c)); err != nil || v != "bar" {
+			t.Errorf("%s(GET) returned %q, %v, want bar, nil", tt.name, v, err)
+		}
+		if dials != 2 {
+			t.Errorf("%s: dials = %d, want 2", tt.name, dials)
+		}
+		if _, err := redis.NetConn(c); err != nil {
+			t.Errorf("%s: NetConn returned %v", tt.name, err)
+		}
+		c.Close()
+		s.close()
+	}
+}
+
+func TestReconnectConnReceiveNoDial(t *testing.T) {
+	s := failingServer(t, "GET")
+	defer s.close()
+
+	dials := 0
+	c := redis.NewReconnectConn(func() (redis.Conn, error) {
+		dials++
+		return redis.Dial("tcp", s.addr())
+	})
+	defer c.Close()
+
+	if _, err := c.Receive(); err == nil {
+		t.Error("Receive before Send returned nil error")
+	}
+	if dials != 0 {
+		t.Errorf("dials after Receive = %d, want 0", dials)
+	}
+
+	c.Send("GET", "foo")
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush returned %v", err)
+	}
+	if _, err := c.Receive(); err == nil {
+		t.Fatal("Receive returned nil error, want connection error")
+	}
+
+	// The reply to GET is lost with the failed connection.
+	if _, err := c.Receive(); err == nil {
+		t.Error("Receive after failure returned nil error")
+	}
+	if err := c.Flush(); err == nil {
+		t.Error("Flush after failure returned nil error")
+	}
+	if dials != 1 {
+		t.Errorf("dials after failure = %d, want 1", dials)
+	}
+
+	if v, err := redis.String(c.Do("GET", "foo")); err != nil || v != "bar" {
+		t.Errorf("Do(GET) returned %q, %v, want bar, nil", v, err)
+	}
+	if dials != 2 {
+		t.Errorf("dials after Do = %d, want 2", dials)
+	}
+}
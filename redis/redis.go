@@ -14,7 +14,19 @@
 
 package redis
 
-// Error represents an error returned in a command reply.
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Error represents an error returned in a command reply. An Error reply
+// indicates that the command was transmitted to the server and rejected by
+// the server. Error replies do not break the connection.
 type Error string
 
 func (err Error) Error() string { return string(err) }
@@ -24,7 +36,10 @@ type Conn interface {
 	// Close closes the connection.
 	Close() error
 
-	// Err returns the permanent error for this connection.
+	// Err returns a non-nil value when the connection is not usable. The
+	// value is the first fatal protocol or I/O error encountered by the
+	// connection. Once set, the error is returned from all subsequent
+	// operations on the connection.
 	Err() error
 
 	// Do sends a command to the server and returns the received reply.
@@ -39,3 +54,405 @@ type Conn interface {
 	// Receive receives a single reply from the Redis server
 	Receive() (reply interface{}, err error)
 }
+
+// ConnWithTimeout is an optional interface that allows the caller to override
+// a connection's default read timeout. This interface is useful for executing
+// the BLPOP, BRPOP, BRPOPLPUSH and other commands that block at the
+// server.
+//
+// A connection's default read timeout is set with the DialTimeout function.
+// Applications should rely on the default timeout for commands that do not
+// block at the server.
+//
+// All of the Conn implementations in this package satisfy the ConnWithTimeout
+// interface.
+//
+// Use the DoWithTimeout function to simplify use of this interface.
+type ConnWithTimeout interface {
+	Conn
+
+	// DoWithTimeout sends a command to the server and returns the received
+	// reply. The timeout overrides the read timeout set when dialing the
+	// connection.
+	DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (reply interface{}, err error)
+}
+
+var errTimeoutNotSupported = errors.New("redigo: connection does not support ConnWithTimeout")
+
+// DoWithTimeout executes a Redis command with the specified read timeout. If
+// the connection does not satisfy the ConnWithTimeout interface, then an error
+// is returned.
+func DoWithTimeout(c Conn, timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	cwt, ok := c.(ConnWithTimeout)
+	if !ok {
+		return nil, errTimeoutNotSupported
+	}
+	return cwt.DoWithTimeout(timeout, cmd, args...)
+}
+
+var errReceiveWithTimeoutNotSupported = errors.New("redigo: connection does not support ReceiveWithTimeout")
+
+// ReceiveWithTimeout receives a single reply from the connection with the
+// specified read timeout. If the timeout expires before the server starts
+// sending a reply, then ReceiveWithTimeout returns a net.Error with Timeout()
+// true and the connection remains usable. Use ReceiveWithTimeout to wait for
+// pushed messages while periodically checking for other work. If the
+// connection does not support ReceiveWithTimeout, then an error is returned.
+func ReceiveWithTimeout(c Conn, timeout time.Duration) (interface{}, error) {
+	crt, ok := c.(interface {
+		ReceiveWithTimeout(timeout time.Duration) (interface{}, error)
+	})
+	if !ok {
+		return nil, errReceiveWithTimeoutNotSupported
+	}
+	return crt.ReceiveWithTimeout(timeout)
+}
+
+// ConnWithContext is an optional interface that allows the caller to control
+// the command's life with a context. If the context is done before the reply
+// is received, then the connection is closed and the context error is
+// returned.
+//
+// All of the Conn implementations in this package satisfy the
+// ConnWithContext interface.
+//
+// Use the DoContext function to simplify use of this interface.
+type ConnWithContext interface {
+	Conn
+
+	// DoContext sends a command to server and returns the received reply.
+	// The deadline and cancellation of ctx bound the command.
+	DoContext(ctx context.Context, commandName string, args ...interface{}) (reply interface{}, err error)
+}
+
+var errContextNotSupported = errors.New("redigo: connection does not support ConnWithContext")
+
+// DoContext sends a command to server and returns the received reply. If the
+// connection does not satisfy the ConnWithContext interface, then an error is
+// returned.
+//
+// Cancellation closes the network connection because the reply to the
+// command may be partially received. The connection Err method returns a
+// non-nil value after cancellation, so a pooled connection is closed instead
+// of returned to the pool when the application closes it. A reply that is
+// partially received never corrupts the reply to a later command.
+func DoContext(c Conn, ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	cwc, ok := c.(ConnWithContext)
+	if !ok {
+		return nil, errContextNotSupported
+	}
+	return cwc.DoContext(ctx, cmd, args...)
+}
+
+var errReceiveReaderNotSupported = errors.New("redigo: connection does not support ReceiveReader")
+
+// ReceiveReader receives a single bulk reply from the connection and returns a
+// reader for the reply payload. Use ReceiveReader to stream large values
+// without allocating the entire value in memory:
+//
+//  c.Send("GET", "blob")
+//  c.Flush()
+//  r, err := redis.ReceiveReader(c)
+//  if err != nil {
+//      // handle error
+//  }
+//  io.Copy(w, r)
+//
+// The reader is valid until the next call to a connection method that reads
+// from the server. If the reply is nil, then ReceiveReader returns nil,
+// ErrNil. If the reply is not a bulk reply, then the reply is discarded and
+// ReceiveReader returns an error. ReceiveReader returns an error if the
+// connection does not support streaming replies.
+func ReceiveReader(c Conn) (io.Reader, error) {
+	cr, ok := c.(interface {
+		ReceiveReader() (io.Reader, error)
+	})
+	if !ok {
+		return nil, errReceiveReaderNotSupported
+	}
+	return cr.ReceiveReader()
+}
+
+// ReceiveTo receives a single bulk reply from the connection and copies the
+// reply payload to w. ReceiveTo returns the number of bytes copied. Use
+// ReceiveTo to write a large value to a file or network connection without
+// allocating the entire value in memory. ReceiveTo returns an error for nil
+// and non-bulk replies as described for ReceiveReader. If writing to w fails,
+// then the unread payload is discarded by the next read from the connection.
+func ReceiveTo(c Conn, w io.Writer) (int64, error) {
+	r, err := ReceiveReader(c)
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(w, r)
+}
+
+var errCopyReplyNotSupported = errors.New("redigo: connection does not support CopyReply")
+
+// CopyReply receives a single reply from the connection and writes the wire
+// encoding of the reply to w without converting the reply to Go values. Use
+// CopyReply with DoRaw or EncodeCommand to forward commands and replies
+// between connections:
+//
+//  c.Send("GET", "foo")
+//  c.Flush()
+//  if err := redis.CopyReply(c, w); err != nil {
+//      // handle error
+//  }
+//
+// Error replies are written to w and are not returned as errors. The
+// connection is not usable after an error writing to w. CopyReply returns an
+// error if the connection does not support copying replies.
+func CopyReply(c Conn, w io.Writer) error {
+	cc, ok := c.(interface {
+		CopyReply(w io.Writer) error
+	})
+	if !ok {
+		return errCopyReplyNotSupported
+	}
+	return cc.CopyReply(w)
+}
+
+var errNetConnNotSupported = errors.New("redigo: connection does not support NetConn")
+
+// NetConn returns the network connection underlying c. Use NetConn to set
+// socket options that are not exposed by the dial options:
+//
+//  nc, err := redis.NetConn(c)
+//  if err != nil {
+//      // handle error
+//  }
+//  if tc, ok := nc.(*net.TCPConn); ok {
+//      tc.SetNoDelay(false)
+//  }
+//
+// Changing the network connection concurrently with commands on c is not
+// safe. NetConn returns an error if the connection does not expose a network
+// connection.
+func NetConn(c Conn) (net.Conn, error) {
+	cn, ok := c.(interface {
+		NetConn() net.Conn
+	})
+	if !ok {
+		return nil, errNetConnNotSupported
+	}
+	nc := cn.NetConn()
+	if nc == nil {
+		return nil, errNetConnNotSupported
+	}
+	return nc, nil
+}
+
+var errSendInlineNotSupported = errors.New("redigo: connection does not support SendInline")
+
+// SendInline writes a command to the connection's output buffer using the
+// inline command format instead of the multi-bulk format. Inline commands are
+// useful for testing interoperability with servers that implement a subset of
+// the Redis protocol. The parts of an inline command must be nonempty and must
+// not contain whitespace. Replies to inline commands are received with the
+// Receive method.
+func SendInline(c Conn, parts ...string) error {
+	cs, ok := c.(interface {
+		SendInline(parts ...string) error
+	})
+	if !ok {
+		return errSendInlineNotSupported
+	}
+	return cs.SendInline(parts...)
+}
+
+var errOnPushNotSupported = errors.New("redigo: connection does not support OnPush")
+
+// OnPush sets the handler for RESP3 push messages received on the connection.
+// Push messages, such as client side caching invalidation messages, are sent
+// by the server at any time and are interleaved with command replies. When a
+// handler is set, the connection delivers push messages to the handler and
+// returns only command replies from Do and Receive. When no handler is set,
+// push messages are returned from Receive as []interface{} values. A nil
+// handler removes the current handler.
+//
+// The handler is called from the goroutine that reads replies from the
+// connection and must not use the connection. The handler is removed when a
+// pooled connection is returned to the pool. OnPush returns an error if the
+// connection does not support push handlers.
+func OnPush(c Conn, f func(push []interface{})) error {
+	cp, ok := c.(interface {
+		OnPush(f func(push []interface{}))
+	})
+	if !ok {
+		return errOnPushNotSupported
+	}
+	cp.OnPush(f)
+	return nil
+}
+
+// Discard closes the connection. If the connection was obtained from a Pool,
+// then Discard closes the underlying network connection instead of returning
+// the connection to the pool. For other connections, Discard is equivalent to
+// calling the connection Close method.
+func Discard(c Conn) error {
+	if cd, ok := c.(interface {
+		Discard() error
+	}); ok {
+		return cd.Discard()
+	}
+	return c.Close()
+}
+
+var errDoRawNotSupported = errors.New("redigo: connection does not support DoRaw")
+
+// EncodeCommand returns the wire encoding of a command. The arguments are
+// encoded using the same rules as the connection Do and Send methods. Use
+// EncodeCommand with DoRaw to send a frequently used command without encoding
+// the arguments on each call:
+//
+//  ping := redis.EncodeCommand("PING")
+//  ...
+//  reply, err := redis.DoRaw(c, ping)
+//
+// EncodeCommand panics if the command name is empty. Use AppendCommand to
+// encode a command name that is not known to be valid.
+func EncodeCommand(cmd string, args ...interface{}) []byte {
+	if cmd == "" {
+		panic("redigo: EncodeCommand command name is empty")
+	}
+	return appendCommand(nil, cmd, args)
+}
+
+// Command returns the wire encoding of a command as written by the connection
+// Send method. Use Command to verify the encoding of commands in tests without
+// a server:
+//
+//  p, err := redis.Command("SET", "foo", 42)
+//  // p is []byte("*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$2\r\n42\r\n")
+//
+// Command is equivalent to AppendCommand(nil, cmd, args...). Unlike
+// EncodeCommand, Command returns an error instead of panicking if the command
+// name is empty.
+func Command(cmd string, args ...interface{}) ([]byte, error) {
+	return AppendCommand(nil, cmd, args...)
+}
+
+// AppendCommand appends the wire encoding of a command to buf and returns the
+// extended buffer. The arguments are encoded using the same rules as the
+// connection Do and Send methods. Pass a buffer from a previous call with the
+// length set to zero to encode commands without allocating:
+//
+//  buf, err = redis.AppendCommand(buf[:0], "GET", key)
+//
+// AppendCommand returns an error if the command name is empty.
+func AppendCommand(buf []byte, cmd string, args ...interface{}) ([]byte, error) {
+	if cmd == "" {
+		return buf, errors.New("redigo: AppendCommand command name is empty")
+	}
+	return appendCommand(buf, cmd, args), nil
+}
+
+// appendCommand appends the wire encoding of a command to buf. All command
+// encoding in the package goes through appendCommand.
+func appendCommand(buf []byte, cmd string, args []interface{}) []byte {
+	buf = appendLen(buf, '*', 1+len(args))
+	buf = appendBulkString(buf, cmd)
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case string:
+			buf = appendBulkString(buf, arg)
+		case []byte:
+			buf = appendLen(buf, '$', len(arg))
+			buf = append(buf, arg...)
+			buf = append(buf, "\r\n"...)
+		case bool:
+			if arg {
+				buf = appendBulkString(buf, "1")
+			} else {
+				buf = appendBulkString(buf, "0")
+			}
+		case float64:
+			buf = appendBulkString(buf, strconv.FormatFloat(arg, 'g', -1, 64))
+		case float32:
+			buf = appendBulkString(buf, strconv.FormatFloat(float64(arg), 'g', -1, 32))
+		case time.Time:
+			buf = appendBulkString(buf, strconv.FormatInt(arg.Unix(), 10))
+		case time.Duration:
+			// Round up so that a short positive duration does not expire a
+			// key immediately.
+			n := int64(arg / time.Second)
+			if arg%time.Second > 0 {
+				n++
+			}
+			buf = appendBulkString(buf, strconv.FormatInt(n, 10))
+		case nil:
+			buf = appendBulkString(buf, "")
+		default:
+			buf = appendBulkString(buf, fmt.Sprint(arg))
+		}
+	}
+	return buf
+}
+
+func appendLen(buf []byte, prefix byte, n int) []byte {
+	buf = append(buf, prefix)
+	buf = strconv.AppendInt(buf, int64(n), 10)
+	return append(buf, "\r\n"...)
+}
+
+func appendBulkString(buf []byte, s string) []byte {
+	buf = appendLen(buf, '$', len(s))
+	buf = append(buf, s...)
+	return append(buf, "\r\n"...)
+}
+
+// DoRaw sends a command encoded by EncodeCommand or AppendCommand to the server
+// and returns the reply. The serialized command must contain exactly one
+// command. DoRaw returns an error if the connection does not support sending
+// encoded commands.
+func DoRaw(c Conn, serialized []byte) (interface{}, error) {
+	cr, ok := c.(interface {
+		DoRaw(serialized []byte) (interface{}, error)
+	})
+	if !ok {
+		return nil, errDoRawNotSupported
+	}
+	return cr.DoRaw(serialized)
+}
+
+// Ping checks the connection by sending the PING command and checking for the
+// PONG reply. Ping is suitable for use in a Pool TestOnBorrow function. Ping
+// returns an error if the command fails or if the reply is not PONG. Do not
+// use Ping on a connection in subscriber mode.
+func Ping(c Conn) error {
+	if cp, ok := c.(interface {
+		Ping() error
+	}); ok {
+		return cp.Ping()
+	}
+	reply, err := String(c.Do("PING"))
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("redigo: unexpected reply to PING: %v", reply)
+	}
+	return nil
+}
+
+var errReceiveWithKindNotSupported = errors.New("redigo: connection does not support ReceiveWithKind")
+
+// ReceiveWithKind receives a single reply from the connection and returns the
+// reply with the protocol type byte of the reply. The type byte is one of '+'
+// (status), '-' (error), ':' (integer), '$' (bulk) and '*' (multi-bulk), or one
+// of '_' (null), '%' (map), '~' (set), ',' (double), '#' (boolean), '='
+// (verbatim), '(' (big number) and '>' (push) for RESP3 replies. Use
+// ReceiveWithKind in tools that re-encode replies. The reply values are as
+// returned by Receive; the value of a big number reply is a *big.Int.
+// ReceiveWithKind returns an error if the connection does not report reply
+// kinds.
+func ReceiveWithKind(c Conn) (byte, interface{}, error) {
+	ck, ok := c.(interface {
+		ReceiveWithKind() (byte, interface{}, error)
+	})
+	if !ok {
+		return 0, nil, errReceiveWithKindNotSupported
+	}
+	return ck.ReceiveWithKind()
+}
@@ -15,13 +15,51 @@
 package redis
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
+	"time"
 )
 
+// ErrNil indicates that a reply value is nil. The Redis server returns a nil
+// reply for a key that does not exist. The reply helpers return ErrNil when
+// converting a nil reply.
 var ErrNil = errors.New("redigo: nil returned")
 
+// resp2Value converts the RESP3 double, boolean and big number reply values to
+// the bulk and integer values returned for the same commands with RESP2. The
+// helpers convert replies with resp2Value so that they work with RESP2 and
+// RESP3 replies.
+func resp2Value(reply interface{}) interface{} {
+	switch reply := reply.(type) {
+	case float64:
+		return []byte(strconv.FormatFloat(reply, 'g', -1, 64))
+	case bool:
+		if reply {
+			return int64(1)
+		}
+		return int64(0)
+	case *big.Int:
+		return []byte(reply.String())
+	}
+	return reply
+}
+
+// IsNil reports whether err indicates a nil reply. Use IsNil to distinguish a
+// missing key from other errors:
+//
+//  v, err := redis.String(c.Do("GET", "foo"))
+//  if redis.IsNil(err) {
+//      // foo does not exist
+//  } else if err != nil {
+//      // handle error
+//  }
+func IsNil(err error) bool {
+	return err == ErrNil
+}
+
 // Int is a helper that converts a command reply to an integer. If err is not
 // equal to nil, then Int returns 0, err. Otherwise, Int converts the
 // reply to an int as follows:
@@ -35,7 +73,7 @@ func Int(reply interface{}, err error) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	switch reply := reply.(type) {
+	switch reply := resp2Value(reply).(type) {
 	case int64:
 		x := int(reply)
 		if int64(x) != reply {
@@ -53,20 +91,104 @@ func Int(reply interface{}, err error) (int, error) {
 	return 0, fmt.Errorf("redigo: unexpected type for Int, got type %T", reply)
 }
 
+// Int64 is a helper that converts a command reply to 64 bit integer. If err is
+// not equal to nil, then Int64 returns 0, err. Otherwise, Int64 converts the
+// reply to an int64 as follows:
+//
+//  Reply type    Result
+//  integer       reply, nil
+//  bulk          strconv.ParseInt(reply, 10, 64)
+//  nil           0, ErrNil
+//  other         0, error
+func Int64(reply interface{}, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch reply := resp2Value(reply).(type) {
+	case int64:
+		return reply, nil
+	case []byte:
+		return strconv.ParseInt(string(reply), 10, 64)
+	case nil:
+		return 0, ErrNil
+	case Error:
+		return 0, reply
+	}
+	return 0, fmt.Errorf("redigo: unexpected type for Int64, got type %T", reply)
+}
+
+var errNegativeInt = errors.New("redigo: unexpected value for Uint64")
+
+// Uint64 is a helper that converts a command reply to 64 bit unsigned
+// integer. If err is not equal to nil, then Uint64 returns 0, err. Otherwise,
+// Uint64 converts the reply to an uint64 as follows:
+//
+//  Reply type    Result
+//  integer       uint64(reply), nil or error if reply is negative
+//  bulk          strconv.ParseUint(reply, 10, 64)
+//  nil           0, ErrNil
+//  other         0, error
+func Uint64(reply interface{}, err error) (uint64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch reply := resp2Value(reply).(type) {
+	case int64:
+		if reply < 0 {
+			return 0, errNegativeInt
+		}
+		return uint64(reply), nil
+	case []byte:
+		return strconv.ParseUint(string(reply), 10, 64)
+	case nil:
+		return 0, ErrNil
+	case Error:
+		return 0, reply
+	}
+	return 0, fmt.Errorf("redigo: unexpected type for Uint64, got type %T", reply)
+}
+
+// Float64 is a helper that converts a command reply to 64 bit float. If err is
+// not equal to nil, then Float64 returns 0, err. Otherwise, Float64 converts
+// the reply to a float64 as follows:
+//
+//  Reply type    Result
+//  double        reply, nil
+//  bulk          strconv.ParseFloat(reply, 64)
+//  nil           0, ErrNil
+//  other         0, error
+func Float64(reply interface{}, err error) (float64, error) {
+	if err != nil {
+		return 0, err
+	}
+	if f, ok := reply.(float64); ok {
+		return f, nil
+	}
+	switch reply := resp2Value(reply).(type) {
+	case []byte:
+		return strconv.ParseFloat(string(reply), 64)
+	case nil:
+		return 0, ErrNil
+	case Error:
+		return 0, reply
+	}
+	return 0, fmt.Errorf("redigo: unexpected type for Float64, got type %T", reply)
+}
+
 // String is a helper that converts a command reply to a string. If err is not
 // equal to nil, then String returns "", err. Otherwise String converts the
 // reply to a string as follows:
 //
 //  Reply type      Result
 //  bulk            string(reply), nil
-//  string          reply, nil
+//  status          reply, nil
 //  nil             "",  ErrNil
 //  other           "",  error
 func String(reply interface{}, err error) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	switch reply := reply.(type) {
+	switch reply := resp2Value(reply).(type) {
 	case []byte:
 		return string(reply), nil
 	case string:
@@ -85,14 +207,14 @@ func String(reply interface{}, err error) (string, error) {
 //
 //  Reply type      Result
 //  bulk            reply, nil
-//  string          []byte(reply), nil
+//  status          []byte(reply), nil
 //  nil             nil, ErrNil
 //  other           nil, error
 func Bytes(reply interface{}, err error) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	switch reply := reply.(type) {
+	switch reply := resp2Value(reply).(type) {
 	case []byte:
 		return reply, nil
 	case string:
@@ -105,20 +227,52 @@ func Bytes(reply interface{}, err error) ([]byte, error) {
 	return nil, fmt.Errorf("redigo: unexpected type for Bytes, got type %T", reply)
 }
 
+// JSONRaw is a helper that converts a bulk command reply to a
+// json.RawMessage. If err is not equal to nil, then JSONRaw returns nil, err.
+// Use JSONRaw to embed a JSON document stored in Redis in a larger JSON value
+// without decoding the document. JSONRaw does not validate the document.
+// JSONRaw converts the reply as follows:
+//
+//  Reply type      Result
+//  bulk            json.RawMessage(reply), nil
+//  nil             nil, ErrNil
+//  other           nil, error
+func JSONRaw(reply interface{}, err error) (json.RawMessage, error) {
+	if err != nil {
+		return nil, err
+	}
+	switch reply := reply.(type) {
+	case []byte:
+		return json.RawMessage(reply), nil
+	case nil:
+		return nil, ErrNil
+	case Error:
+		return nil, reply
+	}
+	return nil, fmt.Errorf("redigo: unexpected type for JSONRaw, got type %T", reply)
+}
+
 // Bool is a helper that converts a command reply to a boolean. If err is not
 // equal to nil, then Bool returns false, err. Otherwise Bool converts the
 // reply to boolean as follows:
 //
 //  Reply type      Result
+//  boolean         reply, nil
 //  integer         value != 0, nil
 //  bulk            strconv.ParseBool(reply)
 //  nil             false, ErrNil
 //  other           false, error
+//
+// Bool accepts the "1" and "0" values written for bool command arguments, so a
+// value stored with c.Do("SET", key, true) is read back with
+// redis.Bool(c.Do("GET", key)).
 func Bool(reply interface{}, err error) (bool, error) {
 	if err != nil {
 		return false, err
 	}
 	switch reply := reply.(type) {
+	case bool:
+		return reply, nil
 	case int64:
 		return reply != 0, nil
 	case []byte:
@@ -136,7 +290,7 @@ func MultiBulk(reply interface{}, err error) ([]interface{}, error) { return Val
 
 // Values is a helper that converts a multi-bulk command reply to a
 // []interface{}. If err is not equal to nil, then Values returns nil, err.
-// Otherwise, Multi converts the reply as follows:
+// Otherwise, Values converts the reply as follows:
 //
 //  Reply type      Result
 //  multi-bulk      reply, nil
@@ -154,5 +308,370 @@ func Values(reply interface{}, err error) ([]interface{}, error) {
 	case Error:
 		return nil, reply
 	}
-	return nil, fmt.Errorf("redigo: unexpected type for Multi, got type %T", reply)
+	return nil, fmt.Errorf("redigo: unexpected type for Values, got type %T", reply)
+}
+
+// Strings is a helper that converts a multi-bulk command reply to a []string.
+// If err is not equal to nil, then Strings returns nil, err. Nil elements of
+// the reply are converted to "". Otherwise, Strings converts the reply as
+// follows:
+//
+//  Reply type      Result
+//  multi-bulk      []string with elements converted using String, nil
+//  nil             nil, ErrNil
+//  other           nil, error
+func Strings(reply interface{}, err error) ([]string, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, len(values))
+	for i, v := range values {
+		switch v := resp2Value(v).(type) {
+		case []byte:
+			result[i] = string(v)
+		case string:
+			result[i] = v
+		case nil:
+			// leave as ""
+		default:
+			return nil, fmt.Errorf("redigo: unexpected element type for Strings, got type %T", v)
+		}
+	}
+	return result, nil
+}
+
+// ByteSlices is a helper that converts a multi-bulk command reply to a
+// [][]byte. If err is not equal to nil, then ByteSlices returns nil, err. Nil
+// elements of the reply are converted to nil. Otherwise, ByteSlices converts
+// the reply as follows:
+//
+//  Reply type      Result
+//  multi-bulk      [][]byte, nil
+//  nil             nil, ErrNil
+//  other           nil, error
+//
+// ByteSlices returns an error if an element of the reply is not a bulk value.
+func ByteSlices(reply interface{}, err error) ([][]byte, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	result := make([][]byte, len(values))
+	for i, v := range values {
+		switch v := resp2Value(v).(type) {
+		case []byte:
+			result[i] = v
+		case nil:
+			// leave as nil
+		default:
+			return nil, fmt.Errorf("redigo: unexpected element type for ByteSlices, got type %T", v)
+		}
+	}
+	return result, nil
+}
+
+// ScanResult is a helper that converts a reply from the SCAN, SSCAN, HSCAN or
+// ZSCAN commands to the cursor and the elements of the reply. If err is not
+// equal to nil, then ScanResult returns 0, nil, err. The reply must be a
+// multi-bulk reply with two elements: the cursor as a bulk value and a
+// multi-bulk reply of bulk values. Use ScanResult to iterate with manual
+// control of the cursor:
+//
+//  cursor := uint64(0)
+//  for {
+//      var keys [][]byte
+//      cursor, keys, err = redis.ScanResult(c.Do("SCAN", cursor))
+//      if err != nil {
+//          // handle error
+//      }
+//      // process keys
+//      if cursor == 0 {
+//          break
+//      }
+//  }
+//
+// The NewScanIterator function returns an iterator that manages the cursor.
+func ScanResult(reply interface{}, err error) (uint64, [][]byte, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(values) != 2 {
+		return 0, nil, fmt.Errorf("redigo: ScanResult expects two element reply, got %d elements", len(values))
+	}
+	cursor, err := Uint64(values[0], nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	items, err := ByteSlices(values[1], nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	return cursor, items, nil
+}
+
+// Ints is a helper that converts a multi-bulk command reply to a []int. If err
+// is not equal to nil, then Ints returns nil, err. Otherwise, Ints converts
+// the reply as follows:
+//
+//  Reply type      Result
+//  multi-bulk      []int with elements converted using Int, nil
+//  nil             nil, ErrNil
+//  other           nil, error
+//
+// Ints returns ErrNil if an element of the reply is nil. Ints returns an error
+// if an element of the reply cannot be converted to an int.
+func Ints(reply interface{}, err error) ([]int, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]int, len(values))
+	for i, v := range values {
+		n, err := Int(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = n
+	}
+	return result, nil
+}
+
+// Int64s is a helper that converts a multi-bulk command reply to a []int64. If
+// err is not equal to nil, then Int64s returns nil, err. Otherwise, Int64s
+// converts the reply as follows:
+//
+//  Reply type      Result
+//  multi-bulk      []int64 with elements converted using Int64, nil
+//  nil             nil, ErrNil
+//  other           nil, error
+//
+// Int64s returns ErrNil if an element of the reply is nil. Int64s returns an
+// error if an element of the reply cannot be converted to an int64.
+func Int64s(reply interface{}, err error) ([]int64, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]int64, len(values))
+	for i, v := range values {
+		n, err := Int64(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = n
+	}
+	return result, nil
+}
+
+// Positions is a helper that converts a multi-bulk command reply of
+// longitude and latitude pairs to a []*[2]float64. If err is not equal to nil,
+// then Positions returns nil, err. The GEOPOS command returns replies in this
+// format. Nil elements of the reply, returned for members that do not exist,
+// are converted to nil. Positions returns an error if an element is not a pair
+// of floating point values.
+func Positions(reply interface{}, err error) ([]*[2]float64, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	positions := make([]*[2]float64, len(values))
+	for i := range values {
+		if values[i] == nil {
+			continue
+		}
+		p, ok := values[i].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("redigo: unexpected element type for Positions, got type %T", values[i])
+		}
+		if len(p) != 2 {
+			return nil, fmt.Errorf("redigo: unexpected number of values for a position, got %d", len(p))
+		}
+		lng, err := Float64(p[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		lat, err := Float64(p[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		positions[i] = &[2]float64{lng, lat}
+	}
+	return positions, nil
+}
+
+// StringMap is a helper that converts a multi-bulk command reply containing
+// alternating field names and values to a map[string]string. If err is not
+// equal to nil, then StringMap returns nil, err. The HGETALL and CONFIG GET
+// commands return replies in this format. StringMap returns an error if the
+// reply has an odd number of elements or if an element is not a bulk value.
+func StringMap(reply interface{}, err error) (map[string]string, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("redigo: StringMap expects even number of values in reply")
+	}
+	m := make(map[string]string, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, okKey := values[i].([]byte)
+		value, okValue := resp2Value(values[i+1]).([]byte)
+		if !okKey || !okValue {
+			return nil, errors.New("redigo: StringMap key or value not a bulk value")
+		}
+		m[string(key)] = string(value)
+	}
+	return m, nil
+}
+
+// IntMap is a helper that converts a multi-bulk command reply containing
+// alternating field names and integer values to a map[string]int. If err is
+// not equal to nil, then IntMap returns nil, err. IntMap returns an error if
+// the reply has an odd number of elements or if a value is not an integer.
+func IntMap(reply interface{}, err error) (map[string]int, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("redigo: IntMap expects even number of values in reply")
+	}
+	m := make(map[string]int, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].([]byte)
+		if !ok {
+			return nil, errors.New("redigo: IntMap key not a bulk value")
+		}
+		value, err := Int(values[i+1], nil)
+		if err != nil {
+			return nil, err
+		}
+		m[string(key)] = value
+	}
+	return m, nil
+}
+
+// Int64Map is a helper that converts a multi-bulk command reply containing
+// alternating field names and integer values to a map[string]int64. If err is
+// not equal to nil, then Int64Map returns nil, err. Int64Map returns an error
+// if the reply has an odd number of elements or if a value is not an integer.
+func Int64Map(reply interface{}, err error) (map[string]int64, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("redigo: Int64Map expects even number of values in reply")
+	}
+	m := make(map[string]int64, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].([]byte)
+		if !ok {
+			return nil, errors.New("redigo: Int64Map key not a bulk value")
+		}
+		value, err := Int64(values[i+1], nil)
+		if err != nil {
+			return nil, err
+		}
+		m[string(key)] = value
+	}
+	return m, nil
+}
+
+// Float64Map is a helper that converts a multi-bulk command reply containing
+// alternating field names and floating point values to a map[string]float64.
+// If err is not equal to nil, then Float64Map returns nil, err. Float64Map
+// returns an error if the reply has an odd number of elements or if a value is
+// not a floating point number.
+func Float64Map(reply interface{}, err error) (map[string]float64, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("redigo: Float64Map expects even number of values in reply")
+	}
+	m := make(map[string]float64, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].([]byte)
+		if !ok {
+			return nil, errors.New("redigo: Float64Map key not a bulk value")
+		}
+		value, err := Float64(values[i+1], nil)
+		if err != nil {
+			return nil, err
+		}
+		m[string(key)] = value
+	}
+	return m, nil
+}
+
+// SlowlogEntry represents an entry in the Redis slow log.
+type SlowlogEntry struct {
+	// ID is the unique identifier of the log entry.
+	ID int64
+
+	// Timestamp is the time the logged command was processed.
+	Timestamp time.Time
+
+	// Duration is the time needed to execute the command.
+	Duration time.Duration
+
+	// Args is the command and its arguments.
+	Args []string
+
+	// ClientAddr and ClientName are the address and name of the client that
+	// sent the command. These fields are empty for servers older than Redis
+	// 4.0.
+	ClientAddr string
+	ClientName string
+}
+
+// SlowlogEntries is a helper that converts the reply of the SLOWLOG GET
+// command to a []SlowlogEntry. If err is not equal to nil, then
+// SlowlogEntries returns nil, err. SlowlogEntries returns an error if the
+// reply is not in the format of a SLOWLOG GET reply.
+func SlowlogEntries(reply interface{}, err error) ([]SlowlogEntry, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]SlowlogEntry, len(values))
+	for i, v := range values {
+		fields, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("redigo: unexpected slowlog entry, got type %T", v)
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("redigo: slowlog entry has %d fields, want at least 4", len(fields))
+		}
+		e := &entries[i]
+		if e.ID, err = Int64(fields[0], nil); err != nil {
+			return nil, err
+		}
+		t, err := Int64(fields[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		e.Timestamp = time.Unix(t, 0)
+		d, err := Int64(fields[2], nil)
+		if err != nil {
+			return nil, err
+		}
+		e.Duration = time.Duration(d) * time.Microsecond
+		if e.Args, err = Strings(fields[3], nil); err != nil {
+			return nil, err
+		}
+		if len(fields) >= 6 {
+			if e.ClientAddr, err = String(fields[4], nil); err != nil {
+				return nil, err
+			}
+			if e.ClientName, err = String(fields[5], nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
 }
@@ -15,10 +15,373 @@
 package redis_test
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/garyburd/redigo/redis"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
 )
 
+type valueError struct {
+	v   interface{}
+	err error
+}
+
+func ve(v interface{}, err error) valueError {
+	return valueError{v, err}
+}
+
+var replyTests = []struct {
+	name     interface{}
+	actual   valueError
+	expected valueError
+}{
+	{
+		"int64(1<<40)",
+		ve(redis.Int64(int64(1<<40), nil)),
+		ve(int64(1<<40), nil),
+	},
+	{
+		"int64([]byte(-9223372036854775808))",
+		ve(redis.Int64([]byte("-9223372036854775808"), nil)),
+		ve(int64(-9223372036854775808), nil),
+	},
+	{
+		"int64(nil)",
+		ve(redis.Int64(nil, nil)),
+		ve(int64(0), redis.ErrNil),
+	},
+	{
+		"uint64(1)",
+		ve(redis.Uint64(int64(1), nil)),
+		ve(uint64(1), nil),
+	},
+	{
+		"uint64([]byte(18446744073709551615))",
+		ve(redis.Uint64([]byte("18446744073709551615"), nil)),
+		ve(uint64(18446744073709551615), nil),
+	},
+	{
+		"uint64(nil)",
+		ve(redis.Uint64(nil, nil)),
+		ve(uint64(0), redis.ErrNil),
+	},
+	{
+		"float64(1.5)",
+		ve(redis.Float64([]byte("1.5"), nil)),
+		ve(float64(1.5), nil),
+	},
+	{
+		"float64(double 1.5)",
+		ve(redis.Float64(1.5, nil)),
+		ve(float64(1.5), nil),
+	},
+	{
+		"float64(nil)",
+		ve(redis.Float64(nil, nil)),
+		ve(float64(0), redis.ErrNil),
+	},
+	{
+		"bytes([]byte(v1))",
+		ve(redis.Bytes([]byte("v1"), nil)),
+		ve([]byte("v1"), nil),
+	},
+	{
+		"bytes(status OK)",
+		ve(redis.Bytes("OK", nil)),
+		ve([]byte("OK"), nil),
+	},
+	{
+		"bytes(nil)",
+		ve(redis.Bytes(nil, nil)),
+		ve([]byte(nil), redis.ErrNil),
+	},
+	{
+		"bool(int64(1))",
+		ve(redis.Bool(int64(1), nil)),
+		ve(true, nil),
+	},
+	{
+		"bool([]byte(0))",
+		ve(redis.Bool([]byte("0"), nil)),
+		ve(false, nil),
+	},
+	{
+		"bool(boolean true)",
+		ve(redis.Bool(true, nil)),
+		ve(true, nil),
+	},
+	{
+		"bool(nil)",
+		ve(redis.Bool(nil, nil)),
+		ve(false, redis.ErrNil),
+	},
+	{
+		"values([v1, 2])",
+		ve(redis.Values([]interface{}{[]byte("v1"), int64(2)}, nil)),
+		ve([]interface{}{[]byte("v1"), int64(2)}, nil),
+	},
+	{
+		"values(nil)",
+		ve(redis.Values(nil, nil)),
+		ve([]interface{}(nil), redis.ErrNil),
+	},
+	{
+		"stringMap([k1, v1, k2, v2])",
+		ve(redis.StringMap([]interface{}{[]byte("k1"), []byte("v1"), []byte("k2"), []byte("v2")}, nil)),
+		ve(map[string]string{"k1": "v1", "k2": "v2"}, nil),
+	},
+	{
+		"stringMap(nil)",
+		ve(redis.StringMap(nil, nil)),
+		ve(map[string]string(nil), redis.ErrNil),
+	},
+	{
+		"intMap([k1, 1, k2, -2])",
+		ve(redis.IntMap([]interface{}{[]byte("k1"), []byte("1"), []byte("k2"), []byte("-2")}, nil)),
+		ve(map[string]int{"k1": 1, "k2": -2}, nil),
+	},
+	{
+		"int64Map([k1, 1, k2, 1<<40])",
+		ve(redis.Int64Map([]interface{}{[]byte("k1"), []byte("1"), []byte("k2"), []byte("1099511627776")}, nil)),
+		ve(map[string]int64{"k1": 1, "k2": 1 << 40}, nil),
+	},
+	{
+		"float64Map([k1, 1.5, k2, -2])",
+		ve(redis.Float64Map([]interface{}{[]byte("k1"), []byte("1.5"), []byte("k2"), []byte("-2")}, nil)),
+		ve(map[string]float64{"k1": 1.5, "k2": -2}, nil),
+	},
+	{
+		"float64Map(nil)",
+		ve(redis.Float64Map(nil, nil)),
+		ve(map[string]float64(nil), redis.ErrNil),
+	},
+	{
+		"strings([v1, nil, v2])",
+		ve(redis.Strings([]interface{}{[]byte("v1"), nil, []byte("v2")}, nil)),
+		ve([]string{"v1", "", "v2"}, nil),
+	},
+	{
+		"byteSlices([v1, nil, v2])",
+		ve(redis.ByteSlices([]interface{}{[]byte("v1"), nil, []byte("v2")}, nil)),
+		ve([][]byte{[]byte("v1"), nil, []byte("v2")}, nil),
+	},
+	{
+		"byteSlices(nil)",
+		ve(redis.ByteSlices(nil, nil)),
+		ve([][]byte(nil), redis.ErrNil),
+	},
+	{
+		"ints([1, 2, -3])",
+		ve(redis.Ints([]interface{}{int64(1), []byte("2"), int64(-3)}, nil)),
+		ve([]int{1, 2, -3}, nil),
+	},
+	{
+		"ints(nil)",
+		ve(redis.Ints(nil, nil)),
+		ve([]int(nil), redis.ErrNil),
+	},
+	{
+		"int64s([1, 1<<40])",
+		ve(redis.Int64s([]interface{}{int64(1), []byte("1099511627776")}, nil)),
+		ve([]int64{1, 1 << 40}, nil),
+	},
+	{
+		"ints([1, nil])",
+		ve(redis.Ints([]interface{}{int64(1), nil}, nil)),
+		ve([]int(nil), redis.ErrNil),
+	},
+	{
+		"int64s([1, nil])",
+		ve(redis.Int64s([]interface{}{int64(1), nil}, nil)),
+		ve([]int64(nil), redis.ErrNil),
+	},
+	{
+		"intMap([k1, nil])",
+		ve(redis.IntMap([]interface{}{[]byte("k1"), nil}, nil)),
+		ve(map[string]int(nil), redis.ErrNil),
+	},
+	{
+		"int64s(nil)",
+		ve(redis.Int64s(nil, nil)),
+		ve([]int64(nil), redis.ErrNil),
+	},
+	{
+		"positions([[1.5, 2.5], nil])",
+		ve(redis.Positions([]interface{}{[]interface{}{[]byte("1.5"), []byte("2.5")}, nil}, nil)),
+		ve([]*[2]float64{{1.5, 2.5}, nil}, nil),
+	},
+	{
+		"positions(nil)",
+		ve(redis.Positions(nil, nil)),
+		ve([]*[2]float64(nil), redis.ErrNil),
+	},
+	{
+		"jsonRaw([v1])",
+		ve(redis.JSONRaw([]byte(`{"a":1}`), nil)),
+		ve(json.RawMessage(`{"a":1}`), nil),
+	},
+	{
+		"jsonRaw(nil)",
+		ve(redis.JSONRaw(nil, nil)),
+		ve(json.RawMessage(nil), redis.ErrNil),
+	},
+	{
+		"strings(nil)",
+		ve(redis.Strings(nil, nil)),
+		ve([]string(nil), redis.ErrNil),
+	},
+	{
+		"string(double)",
+		ve(redis.String(1.5, nil)),
+		ve("1.5", nil),
+	},
+	{
+		"bytes(big number)",
+		ve(redis.Bytes(new(big.Int).Lsh(big.NewInt(1), 70), nil)),
+		ve([]byte("1180591620717411303424"), nil),
+	},
+	{
+		"int(boolean)",
+		ve(redis.Int(true, nil)),
+		ve(1, nil),
+	},
+	{
+		"int64(big number)",
+		ve(redis.Int64(big.NewInt(-3), nil)),
+		ve(int64(-3), nil),
+	},
+	{
+		"float64(big number)",
+		ve(redis.Float64(big.NewInt(7), nil)),
+		ve(float64(7), nil),
+	},
+	{
+		"strings(double, big number)",
+		ve(redis.Strings([]interface{}{2.5, big.NewInt(8)}, nil)),
+		ve([]string{"2.5", "8"}, nil),
+	},
+}
+
+func TestReply(t *testing.T) {
+	for _, rt := range replyTests {
+		if rt.actual.err != rt.expected.err {
+			t.Errorf("%s returned err %v, want %v", rt.name, rt.actual.err, rt.expected.err)
+			continue
+		}
+		if !reflect.DeepEqual(rt.actual.v, rt.expected.v) {
+			t.Errorf("%s=%+v, want %+v", rt.name, rt.actual.v, rt.expected.v)
+		}
+	}
+}
+
+var replyErrorTests = []struct {
+	name   string
+	actual valueError
+}{
+	{"uint64(-1)", ve(redis.Uint64(int64(-1), nil))},
+	{"stringMap([k1])", ve(redis.StringMap([]interface{}{[]byte("k1")}, nil))},
+	{"stringMap([k1, 1])", ve(redis.StringMap([]interface{}{[]byte("k1"), int64(1)}, nil))},
+	{"intMap([k1, junk])", ve(redis.IntMap([]interface{}{[]byte("k1"), []byte("junk")}, nil))},
+	{"byteSlices([v1, 1])", ve(redis.ByteSlices([]interface{}{[]byte("v1"), int64(1)}, nil))},
+	{"ints([1, junk])", ve(redis.Ints([]interface{}{int64(1), []byte("junk")}, nil))},
+	{"int64s([v1])", ve(redis.Int64s([]interface{}{"v1"}, nil))},
+	{"positions([[1.5]])", ve(redis.Positions([]interface{}{[]interface{}{[]byte("1.5")}}, nil))},
+	{"positions([[1.5, junk]])", ve(redis.Positions([]interface{}{[]interface{}{[]byte("1.5"), []byte("junk")}}, nil))},
+	{"positions([v1])", ve(redis.Positions([]interface{}{[]byte("v1")}, nil))},
+	{"float64Map([k1])", ve(redis.Float64Map([]interface{}{[]byte("k1")}, nil))},
+	{"float64Map([k1, junk])", ve(redis.Float64Map([]interface{}{[]byte("k1"), []byte("junk")}, nil))},
+	{"int64Map([k1])", ve(redis.Int64Map([]interface{}{[]byte("k1")}, nil))},
+	{"jsonRaw(OK)", ve(redis.JSONRaw("OK", nil))},
+}
+
+func TestSlowlogEntries(t *testing.T) {
+	reply := []interface{}{
+		[]interface{}{int64(2), int64(1339518083), int64(1500), []interface{}{[]byte("KEYS"), []byte("*")}, []byte("127.0.0.1:58217"), []byte("worker")},
+		[]interface{}{int64(1), int64(1339518080), int64(10), []interface{}{[]byte("PING")}},
+	}
+	actual, err := redis.SlowlogEntries(reply, nil)
+	if err != nil {
+		t.Fatalf("SlowlogEntries returned %v", err)
+	}
+	expected := []redis.SlowlogEntry{
+		{
+			ID:         2,
+			Timestamp:  time.Unix(1339518083, 0),
+			Duration:   1500 * time.Microsecond,
+			Args:       []string{"KEYS", "*"},
+			ClientAddr: "127.0.0.1:58217",
+			ClientName: "worker",
+		},
+		{
+			ID:        1,
+			Timestamp: time.Unix(1339518080, 0),
+			Duration:  10 * time.Microsecond,
+			Args:      []string{"PING"},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("SlowlogEntries returned %+v, want %+v", actual, expected)
+	}
+
+	_, err = redis.SlowlogEntries([]interface{}{[]interface{}{int64(1)}}, nil)
+	if want := "redigo: slowlog entry has 1 fields, want at least 4"; err == nil || err.Error() != want {
+		t.Errorf("SlowlogEntries for short entry returned %v, want %s", err, want)
+	}
+	if _, err := redis.SlowlogEntries(nil, nil); err != redis.ErrNil {
+		t.Errorf("SlowlogEntries(nil) returned %v, want %v", err, redis.ErrNil)
+	}
+}
+
+func TestIsNil(t *testing.T) {
+	if _, err := redis.String(nil, nil); !redis.IsNil(err) {
+		t.Errorf("IsNil(%v) = false, want true", err)
+	}
+	if err := errors.New("other"); redis.IsNil(err) {
+		t.Errorf("IsNil(%v) = true, want false", err)
+	}
+	if redis.IsNil(nil) {
+		t.Error("IsNil(nil) = true, want false")
+	}
+}
+
+func TestScanResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		reply  interface{}
+		cursor uint64
+		items  [][]byte
+		ok     bool
+	}{
+		{"items", []interface{}{[]byte("17"), []interface{}{[]byte("k1"), []byte("k2")}}, 17, [][]byte{[]byte("k1"), []byte("k2")}, true},
+		{"empty", []interface{}{[]byte("0"), []interface{}{}}, 0, [][]byte{}, true},
+		{"short", []interface{}{[]byte("0")}, 0, nil, false},
+		{"cursor", []interface{}{[]byte("junk"), []interface{}{}}, 0, nil, false},
+		{"elements", []interface{}{[]byte("0"), []interface{}{int64(1)}}, 0, nil, false},
+	}
+	for _, tt := range tests {
+		cursor, items, err := redis.ScanResult(tt.reply, nil)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: ScanResult returned error %v", tt.name, err)
+			continue
+		}
+		if cursor != tt.cursor || !reflect.DeepEqual(items, tt.items) {
+			t.Errorf("%s: ScanResult = %d, %q, want %d, %q", tt.name, cursor, items, tt.cursor, tt.items)
+		}
+	}
+}
+
+func TestReplyError(t *testing.T) {
+	for _, rt := range replyErrorTests {
+		if rt.actual.err == nil {
+			t.Errorf("%s did not return error", rt.name)
+		}
+	}
+}
+
 func ExampleBool() {
 	c, err := dial()
 	if err != nil {
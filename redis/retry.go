@@ -0,0 +1,184 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// RetryPolicy specifies how a connection returned by NewRetryConn retries
+// commands.
+type RetryPolicy struct {
+	// Maximum number of times a command is retried.
+	MaxRetries int
+
+	// Delay before the first retry. The delay doubles after each retry. If
+	// the value is zero, then a delay of 10 milliseconds is used.
+	InitialBackoff time.Duration
+
+	// Maximum delay between retries. If the value is zero, then the delay is
+	// not limited.
+	MaxBackoff time.Duration
+}
+
+// NewRetryConn returns a connection that retries commands executed with Do,
+// DoWithTimeout, DoContext and DoRaw when the server returns a LOADING or
+// TRYAGAIN error. The server returns these errors while loading the dataset
+// after a restart and during cluster resharding. All other errors are returned
+// to the application immediately. If the command fails after policy.MaxRetries
+// retries, then Do returns the last error.
+//
+// A command is not retried when replies to commands sent with Send are
+// pending, because the error may belong to one of the pending commands.
+// DoContext stops retrying when the context is done.
+func NewRetryConn(c Conn, policy RetryPolicy) Conn {
+	return &retryConn{Conn: c, policy: policy}
+}
+
+type retryConn struct {
+	Conn
+	policy  RetryPolicy
+	pending int
+}
+
+// isRetryable reports whether err is a server error that indicates that the
+// command can succeed if retried later.
+func isRetryable(err error) bool {
+	e, ok := err.(Error)
+	return ok && (strings.HasPrefix(string(e), "LOADING") || strings.HasPrefix(string(e), "TRYAGAIN"))
+}
+
+func (c *retryConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return c.do(context.Background(), func() (interface{}, error) {
+		return c.Conn.Do(cmd, args...)
+	})
+}
+
+func (c *retryConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	return c.do(context.Background(), func() (interface{}, error) {
+		return DoWithTimeout(c.Conn, timeout, cmd, args...)
+	})
+}
+
+func (c *retryConn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	return c.do(ctx, func() (interface{}, error) {
+		return DoContext(c.Conn, ctx, cmd, args...)
+	})
+}
+
+func (c *retryConn) DoRaw(serialized []byte) (interface{}, error) {
+	return c.do(context.Background(), func() (interface{}, error) {
+		return DoRaw(c.Conn, serialized)
+	})
+}
+
+// do executes a command with f and retries the command with backoff as
+// specified by the policy.
+func (c *retryConn) do(ctx context.Context, f func() (interface{}, error)) (interface{}, error) {
+	retry := c.pending == 0
+	c.pending = 0
+	reply, err := f()
+	if !retry {
+		return reply, err
+	}
+	backoff := c.policy.InitialBackoff
+	if backoff == 0 {
+		backoff = 10 * time.Millisecond
+	}
+	for i := 0; i < c.policy.MaxRetries && isRetryable(err); i++ {
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return reply, err
+		case <-t.C:
+		}
+		backoff *= 2
+		if c.policy.MaxBackoff != 0 && backoff > c.policy.MaxBackoff {
+			backoff = c.policy.MaxBackoff
+		}
+		reply, err = f()
+	}
+	return reply, err
+}
+
+func (c *retryConn) Send(cmd string, args ...interface{}) error {
+	if err := c.Conn.Send(cmd, args...); err != nil {
+		return err
+	}
+	c.pending++
+	return nil
+}
+
+func (c *retryConn) SendInline(parts ...string) error {
+	if err := SendInline(c.Conn, parts...); err != nil {
+		return err
+	}
+	c.pending++
+	return nil
+}
+
+// received records that a reply to a pending command was received.
+func (c *retryConn) received() {
+	if c.pending > 0 {
+		c.pending--
+	}
+}
+
+func (c *retryConn) Receive() (interface{}, error) {
+	c.received()
+	return c.Conn.Receive()
+}
+
+func (c *retryConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	c.received()
+	return ReceiveWithTimeout(c.Conn, timeout)
+}
+
+func (c *retryConn) ReceiveWithKind() (byte, interface{}, error) {
+	c.received()
+	return ReceiveWithKind(c.Conn)
+}
+
+func (c *retryConn) ReceiveReader() (io.Reader, error) {
+	c.received()
+	return ReceiveReader(c.Conn)
+}
+
+func (c *retryConn) CopyReply(w io.Writer) error {
+	c.received()
+	return CopyReply(c.Conn, w)
+}
+
+func (c *retryConn) Ping() error {
+	return Ping(c.Conn)
+}
+
+func (c *retryConn) OnPush(f func(push []interface{})) {
+	OnPush(c.Conn, f)
+}
+
+func (c *retryConn) NetConn() net.Conn {
+	nc, _ := NetConn(c.Conn)
+	return nc
+}
+
+func (c *retryConn) Discard() error {
+	return Discard(c.Conn)
+}
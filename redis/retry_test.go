@@ -0,0 +1,142 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestRetryConn(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      string
+		failures int
+		reply    interface{}
+		err      bool
+		sent     int
+	}{
+		{"loading", "GET", 2, []byte("bar"), false, 3},
+		{"tryagain", "MGET", 1, []byte("bar"), false, 2},
+		{"exhausted", "GET", 4, nil, true, 4},
+		{"wrongtype", "HSET", 0, nil, true, 1},
+	}
+	for _, tt := range tests {
+		var mu sync.Mutex
+		failures := tt.failures
+		s := newFakeServer(t, func(args []string) string {
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case args[0] == "HSET":
+				return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+			case failures > 0 && args[0] == "MGET":
+				failures--
+				return "-TRYAGAIN Multiple keys request during rehashing of slot\r\n"
+			case failures > 0:
+				failures--
+				return "-LOADING Redis is loading the dataset in memory\r\n"
+			}
+			return "$3\r\nbar\r\n"
+		})
+		c, err := redis.Dial("tcp", s.addr())
+		if err != nil {
+			t.Fatalf("Dial returned %v", err)
+		}
+		rc := redis.NewRetryConn(c, redis.RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond})
+		reply, err := rc.Do(tt.cmd, "foo")
+		if (err != nil) != tt.err {
+			t.Errorf("%s: Do returned error %v", tt.name, err)
+		}
+		if !tt.err && !reflect.DeepEqual(reply, tt.reply) {
+			t.Errorf("%s: Do returned %v, want %v", tt.name, reply, tt.reply)
+		}
+		if n := len(s.received()); n != tt.sent {
+			t.Errorf("%s: server received %d commands, want %d", tt.name, n, tt.sent)
+		}
+		rc.Close()
+		s.close()
+	}
+}
+
+func TestRetryConnDoMethods(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		do   func(c redis.Conn) (interface{}, error)
+		err  bool
+		sent int
+	}{
+		{
+			"DoWithTimeout",
+			func(c redis.Conn) (interface{}, error) { return redis.DoWithTimeout(c, time.Second, "GET", "foo") },
+			false, 3,
+		},
+		{
+			"DoContext",
+			func(c redis.Conn) (interface{}, error) {
+				return redis.DoContext(c, context.Background(), "GET", "foo")
+			},
+			false, 3,
+		},
+		{
+			"DoRaw",
+			func(c redis.Conn) (interface{}, error) { return redis.DoRaw(c, redis.EncodeCommand("GET", "foo")) },
+			false, 3,
+		},
+		{
+			"DoContext canceled",
+			func(c redis.Conn) (interface{}, error) { return redis.DoContext(c, canceled, "GET", "foo") },
+			true, 0,
+		},
+	}
+	for _, tt := range tests {
+		var mu sync.Mutex
+		failures := 2
+		s := newFakeServer(t, func(args []string) string {
+			mu.Lock()
+			defer mu.Unlock()
+			if failures > 0 {
+				failures--
+				return "-LOADING Redis is loading the dataset in memory\r\n"
+			}
+			return "$3\r\nbar\r\n"
+		})
+		c, err := redis.Dial("tcp", s.addr())
+		if err != nil {
+			t.Fatalf("Dial returned %v", err)
+		}
+		rc := redis.NewRetryConn(c, redis.RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond})
+		reply, err := tt.do(rc)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: returned error %v", tt.name, err)
+		}
+		if !tt.err && !reflect.DeepEqual(reply, []byte("bar")) {
+			t.Errorf("%s: returned %v, want bar", tt.name, reply)
+		}
+		if n := len(s.received()); n != tt.sent {
+			t.Errorf("%s: server received %d commands, want %d", tt.name, n, tt.sent)
+		}
+		rc.Close()
+		s.close()
+	}
+}
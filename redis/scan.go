@@ -87,25 +87,33 @@ func convertAssignInt(d reflect.Value, s int64) (err error) {
 	return
 }
 
+func convertAssignValue(d reflect.Value, s interface{}) (err error) {
+	switch s := resp2Value(s).(type) {
+	case []byte:
+		err = convertAssignBytes(d, s)
+	case int64:
+		err = convertAssignInt(d, s)
+	default:
+		err = cannotConvert(d, s)
+	}
+	return
+}
+
+func ensureLen(d reflect.Value, n int) {
+	if n > d.Cap() {
+		d.Set(reflect.MakeSlice(d.Type(), n, n))
+	} else {
+		d.SetLen(n)
+	}
+}
+
 func convertAssignValues(d reflect.Value, s []interface{}) (err error) {
 	if d.Type().Kind() != reflect.Slice {
 		return cannotConvert(d, s)
 	}
-	if len(s) > d.Cap() {
-		d.Set(reflect.MakeSlice(d.Type(), len(s), len(s)))
-	} else {
-		d.SetLen(len(s))
-	}
+	ensureLen(d, len(s))
 	for i := 0; i < len(s); i++ {
-		switch s := s[i].(type) {
-		case []byte:
-			err = convertAssignBytes(d.Index(i), s)
-		case int64:
-			err = convertAssignInt(d.Index(i), s)
-		default:
-			err = cannotConvert(d, s)
-		}
-		if err != nil {
+		if err = convertAssignValue(d.Index(i), s[i]); err != nil {
 			break
 		}
 	}
@@ -115,9 +123,9 @@ func convertAssignValues(d reflect.Value, s []interface{}) (err error) {
 func convertAssign(d interface{}, s interface{}) (err error) {
 	// Handle the most common destination types using type switches and
 	// fall back to reflection for all other types.
-	switch s := s.(type) {
+	switch s := resp2Value(s).(type) {
 	case nil:
-		// ingore
+		// ignore
 	case []byte:
 		switch d := d.(type) {
 		case *string:
@@ -319,7 +327,9 @@ func structSpecForType(t reflect.Type) *structSpec {
 //
 //      Field int `redis:"myName"`
 //
-// Fields with the tag redis:"-" are ignored.
+// Fields with the tag redis:"-" are ignored. Pointer fields are allocated
+// when a value for the field is present in src, so a nil pointer field
+// indicates that the value is absent.
 func ScanStruct(src []interface{}, dest interface{}) error {
 	d := reflect.ValueOf(dest)
 	if d.Kind() != reflect.Ptr || d.IsNil() {
@@ -329,7 +339,7 @@ func ScanStruct(src []interface{}, dest interface{}) error {
 	ss := structSpecForType(d.Type())
 
 	if len(src)%2 != 0 {
-		return errors.New("redigo: ScanStruct expects even number of values in values")
+		return errors.New("redigo: ScanStruct expects even number of values in src")
 	}
 
 	for i := 0; i < len(src); i += 2 {
@@ -342,6 +352,14 @@ func ScanStruct(src []interface{}, dest interface{}) error {
 			continue
 		}
 		f := d.FieldByIndex(fs.index)
+		if src[i+1] != nil {
+			for f.Kind() == reflect.Ptr {
+				if f.IsNil() {
+					f.Set(reflect.New(f.Type().Elem()))
+				}
+				f = f.Elem()
+			}
+		}
 		var err error
 		switch s := src[i+1].(type) {
 		case nil:
@@ -359,3 +377,236 @@ func ScanStruct(src []interface{}, dest interface{}) error {
 	}
 	return nil
 }
+
+// Unmarshal is a helper that scans a reply containing alternating names and
+// values to the struct pointed to by dest. If err is not equal to nil, then
+// Unmarshal returns err. Use Unmarshal to read a hash into a struct:
+//
+//  var p struct {
+//      Title  string `redis:"title"`
+//      Author string `redis:"author"`
+//      Rating *int   `redis:"rating"`
+//  }
+//  reply, err := c.Do("HGETALL", "album:1")
+//  if err := redis.Unmarshal(reply, err, &p); err != nil {
+//      // handle error
+//  }
+//
+// Fields are matched to names as described for ScanStruct. Fields without a
+// value in the reply are not modified.
+func Unmarshal(reply interface{}, err error, dest interface{}) error {
+	values, err := Values(reply, err)
+	if err != nil {
+		return err
+	}
+	return ScanStruct(values, dest)
+}
+
+var errScanSliceValue = errors.New("redigo: ScanSlice dest must be non-nil pointer to a slice")
+
+// ScanSlice scans multi-bulk src to the slice pointed to by dest. The elements
+// of the dest slice must be integer, float, boolean, string, struct or pointer
+// to struct values.
+//
+// Struct fields must be integer, float, boolean or string values. All struct
+// fields are used unless a subset is specified using fieldNames. Each struct
+// is scanned from the next len(fieldNames) values of src, so src must contain
+// a multiple of the struct field count values. The SORT command with multiple
+// GET patterns returns replies in this format.
+//
+// If the elements of src are multi-bulk values, then each element is scanned
+// to one struct. Each element must contain one value for each struct field.
+// Use this form to scan the replies to HMGET commands pipelined for several
+// hashes:
+//
+//  for _, id := range ids {
+//      c.Send("HMGET", "album:"+id, "Title", "Rating")
+//  }
+//  c.Flush()
+//  replies, err := redis.ReceiveN(c, len(ids))
+//  ...
+//  var albums []struct {
+//      Title  string
+//      Rating int
+//  }
+//  err = redis.ScanSlice(replies, &albums, "Title", "Rating")
+//
+// Nil values in src are skipped and leave the corresponding destination value
+// unmodified.
+func ScanSlice(src []interface{}, dest interface{}, fieldNames ...string) error {
+	d := reflect.ValueOf(dest)
+	if d.Kind() != reflect.Ptr || d.IsNil() {
+		return errScanSliceValue
+	}
+	d = d.Elem()
+	if d.Kind() != reflect.Slice {
+		return errScanSliceValue
+	}
+
+	isPtr := false
+	t := d.Type().Elem()
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+		isPtr = true
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		ensureLen(d, len(src))
+		for i, s := range src {
+			if s == nil {
+				continue
+			}
+			if err := convertAssignValue(d.Index(i), s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ss := structSpecForType(t)
+	fss := ss.l
+	if len(fieldNames) > 0 {
+		fss = make([]*fieldSpec, len(fieldNames))
+		for i, name := range fieldNames {
+			fss[i] = ss.m[name]
+			if fss[i] == nil {
+				return errors.New("redigo: ScanSlice bad field name " + name)
+			}
+		}
+	}
+
+	if len(fss) == 0 {
+		return errors.New("redigo: ScanSlice no struct fields")
+	}
+
+	var rows [][]interface{}
+	if len(src) > 0 {
+		if _, nested := src[0].([]interface{}); nested {
+			rows = make([][]interface{}, len(src))
+			for i, s := range src {
+				row, ok := s.([]interface{})
+				if !ok {
+					return errors.New("redigo: ScanSlice mixes nested and flat values")
+				}
+				if len(row) != len(fss) {
+					return errors.New("redigo: ScanSlice nested value length not equal to struct field count")
+				}
+				rows[i] = row
+			}
+		}
+	}
+	if rows == nil {
+		n := len(src) / len(fss)
+		if n*len(fss) != len(src) {
+			return errors.New("redigo: ScanSlice length not a multiple of struct field count")
+		}
+		rows = make([][]interface{}, n)
+		for i := range rows {
+			rows[i] = src[i*len(fss) : (i+1)*len(fss)]
+		}
+	}
+
+	ensureLen(d, len(rows))
+	for i, row := range rows {
+		d := d.Index(i)
+		if isPtr {
+			if d.IsNil() {
+				d.Set(reflect.New(t))
+			}
+			d = d.Elem()
+		}
+		for j, fs := range fss {
+			s := row[j]
+			if s == nil {
+				continue
+			}
+			if err := convertAssignValue(d.FieldByIndex(fs.index), s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Args is a helper for constructing command arguments from structured values.
+type Args []interface{}
+
+// Add returns the result of appending value to args.
+func (args Args) Add(value ...interface{}) Args {
+	return append(args, value...)
+}
+
+// AddFlat returns the result of appending the flattened value of v to args.
+//
+// Maps are flattened by appending the alternating keys and map values to args.
+//
+// Slices are flattened by appending the slice elements to args. A []byte is
+// appended to args as is. Use AddFlat to pass a slice of values to a variadic
+// command:
+//
+//  c.Do("SADD", redis.Args{}.Add("key").AddFlat([]string{"a", "b", "c"})...)
+//
+// Structs are flattened by appending the alternating names and values of
+// exported fields to args. If v is a nil struct pointer, then nothing is
+// appended. The 'redis' field tag overrides struct field names. See
+// ScanStruct for more information on the use of the 'redis' field tag.
+//
+// Other types are appended to args as is.
+func (args Args) AddFlat(v interface{}) Args {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Struct:
+		args = flattenStruct(args, rv)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			args = append(args, v)
+		} else {
+			for i := 0; i < rv.Len(); i++ {
+				args = append(args, rv.Index(i).Interface())
+			}
+		}
+	case reflect.Map:
+		for _, k := range rv.MapKeys() {
+			args = append(args, k.Interface(), rv.MapIndex(k).Interface())
+		}
+	case reflect.Ptr:
+		if rv.Type().Elem().Kind() == reflect.Struct {
+			if !rv.IsNil() {
+				args = flattenStruct(args, rv.Elem())
+			}
+		} else {
+			args = append(args, v)
+		}
+	default:
+		args = append(args, v)
+	}
+	return args
+}
+
+// DoFlat executes a command after flattening the map and slice arguments.
+// Maps are flattened to alternating keys and values and slices are flattened
+// to their elements as described for Args.AddFlat. A []byte argument is sent
+// as is. Other arguments, including structs, are sent as is:
+//
+//  _, err := redis.DoFlat(c, "HMSET", "key", map[string]string{"field": "value"})
+func DoFlat(c Conn, cmd string, args ...interface{}) (interface{}, error) {
+	var flat Args
+	for _, arg := range args {
+		switch reflect.ValueOf(arg).Kind() {
+		case reflect.Map, reflect.Slice:
+			flat = flat.AddFlat(arg)
+		default:
+			flat = append(flat, arg)
+		}
+	}
+	return c.Do(cmd, flat...)
+}
+
+func flattenStruct(args Args, v reflect.Value) Args {
+	ss := structSpecForType(v.Type())
+	for _, fs := range ss.l {
+		fv := v.FieldByIndex(fs.index)
+		args = append(args, fs.name, fv.Interface())
+	}
+	return args
+}
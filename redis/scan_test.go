@@ -15,9 +15,11 @@
 package redis_test
 
 import (
+	"errors"
 	"fmt"
 	"github.com/garyburd/redigo/redis"
 	"math"
+	"math/big"
 	"reflect"
 	"testing"
 )
@@ -54,6 +56,12 @@ var scanConversionTests = []struct {
 	{[]interface{}{[]byte("1"), []byte("2")}, []int{1, 2}},
 	{[]interface{}{[]byte("1")}, []byte{1}},
 	{[]interface{}{[]byte("1")}, []bool{true}},
+	{1.5, float64(1.5)},
+	{1.5, "1.5"},
+	{true, true},
+	{false, int(0)},
+	{big.NewInt(109), int64(109)},
+	{[]interface{}{big.NewInt(3), true}, []int{3, 1}},
 }
 
 var scanConversionErrorTests = []struct {
@@ -94,6 +102,28 @@ func TestScanConversionError(t *testing.T) {
 	}
 }
 
+func TestScan(t *testing.T) {
+	src := []interface{}{[]byte("gary"), []byte("skipped"), int64(42), nil, []byte("rest")}
+
+	var name string
+	var age int
+	active := true
+	rest, err := redis.Scan(src, &name, nil, &age, &active)
+	if err != nil {
+		t.Fatalf("Scan returned error %v", err)
+	}
+	if name != "gary" || age != 42 || !active {
+		t.Errorf("Scan assigned name=%q, age=%d, active=%v; want gary, 42, true", name, age, active)
+	}
+	if !reflect.DeepEqual(rest, []interface{}{[]byte("rest")}) {
+		t.Errorf("Scan returned rest %v, want [rest]", rest)
+	}
+
+	if _, err := redis.Scan(src[:1], &name, &age); err == nil {
+		t.Errorf("Scan with short src did not return error")
+	}
+}
+
 func ExampleScan() {
 	c, err := dial()
 	if err != nil {
@@ -140,7 +170,7 @@ var scanStructTests = []struct {
 	value interface{}
 }{
 	{"basic",
-		[]string{"i", "-1234", "u", "5678", "s", "hello", "p", "world", "b", "f", "Bt", "1", "Bf", "0"},
+		[]string{"i", "-1234", "u", "5678", "s", "hello", "p", "world", "b", "f", "Bt", "1", "Bf", "0", "unknown", "skipped"},
 		&struct {
 			I  int    `redis:"i"`
 			U  uint   `redis:"u"`
@@ -153,6 +183,23 @@ var scanStructTests = []struct {
 			-1234, 5678, "hello", []byte("world"), false, true, false,
 		},
 	},
+	{"pointers",
+		[]string{"i", "-1234", "s", "hello"},
+		&struct {
+			I  *int     `redis:"i"`
+			S  **string `redis:"s"`
+			Ab *int     `redis:"absent"`
+		}{
+			intPtr(-1234), stringPtrPtr("hello"), nil,
+		},
+	},
+}
+
+func intPtr(i int) *int { return &i }
+
+func stringPtrPtr(s string) **string {
+	p := &s
+	return &p
 }
 
 func TestScanStruct(t *testing.T) {
@@ -174,3 +221,265 @@ func TestScanStruct(t *testing.T) {
 		}
 	}
 }
+
+var scanStructErrorTests = []struct {
+	title string
+	reply []interface{}
+}{
+	{"odd length", []interface{}{[]byte("i")}},
+	{"key not bulk", []interface{}{int64(1), []byte("2")}},
+	{"bad int", []interface{}{[]byte("i"), []byte("junk")}},
+	{"bad type", []interface{}{[]byte("i"), []interface{}{[]byte("1")}}},
+}
+
+func TestScanStructError(t *testing.T) {
+	for _, tt := range scanStructErrorTests {
+		var v struct {
+			I int `redis:"i"`
+		}
+		if err := redis.ScanStruct(tt.reply, &v); err == nil {
+			t.Errorf("ScanStruct(%s) did not return error", tt.title)
+		}
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	type album struct {
+		Title  string `redis:"title"`
+		Rating *int   `redis:"rating"`
+		Plays  *int   `redis:"plays"`
+	}
+	reply := []interface{}{[]byte("title"), []byte("Earthbound"), []byte("rating"), []byte("5")}
+	a := album{Title: "unknown"}
+	if err := redis.Unmarshal(reply, nil, &a); err != nil {
+		t.Fatalf("Unmarshal returned %v", err)
+	}
+	if expected := (album{Title: "Earthbound", Rating: intPtr(5)}); !reflect.DeepEqual(a, expected) {
+		t.Errorf("Unmarshal = %+v, want %+v", a, expected)
+	}
+
+	errReply := errors.New("reply error")
+	if err := redis.Unmarshal(nil, errReply, &a); err != errReply {
+		t.Errorf("Unmarshal returned %v, want %v", err, errReply)
+	}
+	if err := redis.Unmarshal(nil, nil, &a); err != redis.ErrNil {
+		t.Errorf("Unmarshal(nil) returned %v, want %v", err, redis.ErrNil)
+	}
+}
+
+var scanSliceTests = []struct {
+	src        []interface{}
+	fieldNames []string
+	ok         bool
+	dest       interface{}
+}{
+	{
+		[]interface{}{[]byte("1"), nil, []byte("-1")},
+		nil,
+		true,
+		[]int{1, 0, -1},
+	},
+	{
+		[]interface{}{[]byte("a1"), []byte("b1"), []byte("a2"), []byte("b2")},
+		nil,
+		true,
+		[]struct{ A, B string }{{"a1", "b1"}, {"a2", "b2"}},
+	},
+	{
+		[]interface{}{[]byte("a1"), []byte("b1"), []byte("a2"), []byte("b2")},
+		nil,
+		true,
+		[]*struct{ A, B string }{{"a1", "b1"}, {"a2", "b2"}},
+	},
+	{
+		[]interface{}{[]byte("b1"), []byte("a1"), []byte("b2"), []byte("a2")},
+		[]string{"B", "A"},
+		true,
+		[]struct{ A, B string }{{"a1", "b1"}, {"a2", "b2"}},
+	},
+	{
+		[]interface{}{[]interface{}{[]byte("a1"), []byte("b1")}, []interface{}{[]byte("a2"), nil}},
+		nil,
+		true,
+		[]struct{ A, B string }{{"a1", "b1"}, {"a2", ""}},
+	},
+	{
+		[]interface{}{[]interface{}{[]byte("b1"), []byte("a1")}},
+		[]string{"B", "A"},
+		true,
+		[]*struct{ A, B string }{{"a1", "b1"}},
+	},
+	{
+		[]interface{}{[]interface{}{[]byte("a1"), []byte("b1")}, []interface{}{[]byte("a2")}},
+		nil,
+		false,
+		[]struct{ A, B string }{},
+	},
+	{
+		[]interface{}{[]interface{}{[]byte("a1"), []byte("b1")}, []byte("a2")},
+		nil,
+		false,
+		[]struct{ A, B string }{},
+	},
+	{
+		[]interface{}{[]byte("a1"), []byte("b1"), []byte("a2")},
+		nil,
+		false,
+		[]struct{ A, B string }{},
+	},
+	{
+		[]interface{}{[]byte("a1"), []byte("b1")},
+		[]string{"C"},
+		false,
+		[]struct{ A, B string }{},
+	},
+}
+
+func TestScanSlice(t *testing.T) {
+	for _, tt := range scanSliceTests {
+
+		typ := reflect.ValueOf(tt.dest).Type()
+		dest := reflect.New(typ)
+
+		err := redis.ScanSlice(tt.src, dest.Interface(), tt.fieldNames...)
+		if tt.ok != (err == nil) {
+			t.Errorf("ScanSlice(%v, []%s, %v) returned error %v", tt.src, typ, tt.fieldNames, err)
+			continue
+		}
+		if tt.ok && !reflect.DeepEqual(dest.Elem().Interface(), tt.dest) {
+			t.Errorf("ScanSlice(src, []%s) returned %#v, want %#v", typ, dest.Elem().Interface(), tt.dest)
+		}
+	}
+}
+
+var argsTests = []struct {
+	title    string
+	actual   redis.Args
+	expected redis.Args
+}{
+	{"struct ptr",
+		redis.Args{}.AddFlat(&struct {
+			I  int    `redis:"i"`
+			U  uint   `redis:"u"`
+			S  string `redis:"s"`
+			P  []byte `redis:"p"`
+			Bt bool
+			Bf bool
+		}{
+			-1234, 5678, "hello", []byte("world"), true, false,
+		}),
+		redis.Args{"i", int(-1234), "u", uint(5678), "s", "hello", "p", []byte("world"), "Bt", true, "Bf", false},
+	},
+	{"struct",
+		redis.Args{}.AddFlat(struct{ I int }{123}),
+		redis.Args{"I", 123},
+	},
+	{"nil struct ptr",
+		redis.Args{}.AddFlat((*struct{ I int })(nil)),
+		redis.Args{},
+	},
+	{"slice",
+		redis.Args{}.Add(1).AddFlat([]string{"a", "b", "c"}).Add(2),
+		redis.Args{1, "a", "b", "c", 2},
+	},
+	{"int slice",
+		redis.Args{}.Add("key").AddFlat([]int{1, 2, 3}),
+		redis.Args{"key", 1, 2, 3},
+	},
+	{"interface slice",
+		redis.Args{}.Add("key").AddFlat([]interface{}{"a", 1, []byte("b")}),
+		redis.Args{"key", "a", 1, []byte("b")},
+	},
+	{"bytes",
+		redis.Args{}.AddFlat([]byte("hello")),
+		redis.Args{[]byte("hello")},
+	},
+	{"map",
+		redis.Args{}.Add("key").AddFlat(map[string]int{"field": 1}),
+		redis.Args{"key", "field", 1},
+	},
+}
+
+func TestArgs(t *testing.T) {
+	for _, tt := range argsTests {
+		if !reflect.DeepEqual(tt.actual, tt.expected) {
+			t.Fatalf("%s is %v, want %v", tt.title, tt.actual, tt.expected)
+		}
+	}
+}
+
+func TestDoFlat(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	if _, err := redis.DoFlat(c, "HMSET", "key", map[string]int{"field": 1}); err != nil {
+		t.Fatalf("DoFlat returned %v", err)
+	}
+	if _, err := redis.DoFlat(c, "SADD", "key", []string{"a", "b"}, []byte("c"), []int{1}); err != nil {
+		t.Fatalf("DoFlat returned %v", err)
+	}
+
+	expected := [][]string{
+		{"HMSET", "key", "field", "1"},
+		{"SADD", "key", "a", "b", "c", "1"},
+	}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+}
+
+func ExampleArgs() {
+	c, err := dial()
+	if err != nil {
+		panic(err)
+	}
+	defer c.Close()
+
+	var p1, p2 struct {
+		Title  string `redis:"title"`
+		Author string `redis:"author"`
+		Body   string `redis:"body"`
+	}
+
+	p1.Title = "Example"
+	p1.Author = "Gary"
+	p1.Body = "Hello"
+
+	if _, err := c.Do("HMSET", redis.Args{}.Add("id1").AddFlat(&p1)...); err != nil {
+		panic(err)
+	}
+
+	m := map[string]string{
+		"title":  "Example2",
+		"author": "Steve",
+		"body":   "Map",
+	}
+
+	if _, err := c.Do("HMSET", redis.Args{}.Add("id2").AddFlat(m)...); err != nil {
+		panic(err)
+	}
+
+	for _, id := range []string{"id1", "id2"} {
+
+		v, err := redis.Values(c.Do("HGETALL", id))
+		if err != nil {
+			panic(err)
+		}
+
+		if err := redis.ScanStruct(v, &p2); err != nil {
+			panic(err)
+		}
+
+		fmt.Printf("%+v\n", p2)
+	}
+
+	// Output:
+	// {Title:Example Author:Gary Body:Hello}
+	// {Title:Example2 Author:Steve Body:Map}
+}
@@ -55,7 +55,7 @@ func (s *Script) args(spec string, keysAndArgs []interface{}) []interface{} {
 	return args
 }
 
-// Do evalutes the script. Under the covers, Do optimistically evaluates the
+// Do evaluates the script. Under the covers, Do optimistically evaluates the
 // script using the EVALSHA command. If the command fails because the script is
 // not loaded, then Do evaluates the script using the EVAL command (thus
 // causing the script to load).
@@ -84,3 +84,22 @@ func (s *Script) Load(c Conn) error {
 	_, err := c.Do("SCRIPT", "LOAD", s.src)
 	return err
 }
+
+// DoScript evaluates the script src with the EVAL command. DoScript inserts
+// the number of keys in the argument list and sends the keys before the
+// arguments:
+//
+//  v, err := redis.DoScript(c, "return redis.call('SET', KEYS[1], ARGV[1])",
+//      []string{"foo"}, []interface{}{"bar"})
+//
+// Use DoScript for scripts that are evaluated once. Use a Script to evaluate
+// a script repeatedly without sending the source to the server each time.
+func DoScript(c Conn, src string, keys []string, args []interface{}) (interface{}, error) {
+	cmdArgs := make([]interface{}, 0, 2+len(keys)+len(args))
+	cmdArgs = append(cmdArgs, src, len(keys))
+	for _, k := range keys {
+		cmdArgs = append(cmdArgs, k)
+	}
+	cmdArgs = append(cmdArgs, args...)
+	return c.Do("EVAL", cmdArgs...)
+}
@@ -22,14 +22,24 @@ import (
 	"time"
 )
 
-func ExampleScript(c redis.Conn, reply interface{}, err error) {
+func ExampleScript() {
+	c, err := dial()
+	if err != nil {
+		// handle error
+	}
+	defer c.Close()
+
 	// Initialize a package-level variable with a script.
 	var getScript = redis.NewScript(1, `return redis.call('get', KEYS[1])`)
 
 	// In a function, use the script Do method to evaluate the script. The Do
 	// method optimistically uses the EVALSHA command. If the script is not
 	// loaded, then the Do method falls back to the EVAL command.
-	reply, err = getScript.Do(c, "foo")
+	reply, err := getScript.Do(c, "foo")
+	if err != nil {
+		// handle error
+	}
+	fmt.Println(reply)
 }
 
 func TestScript(t *testing.T) {
@@ -86,3 +96,59 @@ func TestScript(t *testing.T) {
 	}
 
 }
+
+func TestScriptFallback(t *testing.T) {
+	s := newFakeServer(t, func(args []string) string {
+		if args[0] == "EVALSHA" {
+			return "-NOSCRIPT No matching script. Please use EVAL.\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	script := redis.NewScript(1, "return redis.call('get', KEYS[1])")
+	if _, err := script.Do(c, "key1", "arg1"); err != nil {
+		t.Fatalf("script.Do returned %v", err)
+	}
+
+	expected := [][]string{
+		{"EVALSHA", "4e6d8fc8bb01276962cce5371fa795a7763657ae", "1", "key1", "arg1"},
+		{"EVAL", "return redis.call('get', KEYS[1])", "1", "key1", "arg1"},
+	}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+}
+
+func TestDoScript(t *testing.T) {
+	s := newFakeServer(t, nil)
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	src := "return redis.call('set', KEYS[1], ARGV[1])"
+	if _, err := redis.DoScript(c, src, []string{"key1", "key2"}, []interface{}{"arg1", 2}); err != nil {
+		t.Fatalf("DoScript returned %v", err)
+	}
+	if _, err := redis.DoScript(c, src, nil, nil); err != nil {
+		t.Fatalf("DoScript returned %v", err)
+	}
+
+	expected := [][]string{
+		{"EVAL", src, "2", "key1", "key2", "arg1", "2"},
+		{"EVAL", src, "0"},
+	}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// SentinelGetMasterAddr returns the address of the master named masterName
+// using the Redis Sentinel connection c. The address is returned in the
+// host:port form used by Dial. If the Sentinel does not monitor a master with
+// the given name, then SentinelGetMasterAddr returns ErrNil.
+func SentinelGetMasterAddr(c Conn, masterName string) (string, error) {
+	reply, err := Strings(c.Do("SENTINEL", "get-master-addr-by-name", masterName))
+	if err != nil {
+		return "", err
+	}
+	if len(reply) != 2 {
+		return "", errors.New("redigo: unexpected reply to SENTINEL get-master-addr-by-name")
+	}
+	return net.JoinHostPort(reply[0], reply[1]), nil
+}
+
+// DialVerifyMasterRole specifies that DialSentinel verifies with the ROLE
+// command that the server reported by a Sentinel is a master. Use the option
+// to detect a Sentinel that reports a stale master during failover.
+func DialVerifyMasterRole() DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.verifyRole = true
+	}}
+}
+
+// DialSentinel connects to the master named masterName. DialSentinel asks each
+// Sentinel in sentinelAddrs in turn for the address of the master and dials
+// the first master found. If a Sentinel is not reachable, does not know the
+// master or reports a master that cannot be dialed, then DialSentinel tries
+// the next Sentinel.
+//
+// The options apply to the connection to the master. The timeout, TCP and
+// DialNetDial options also apply to the connections to the Sentinels. If
+// no Sentinel reports a usable master, then DialSentinel returns an error
+// describing the failure for each Sentinel.
+func DialSentinel(sentinelAddrs []string, masterName string, options ...DialOption) (Conn, error) {
+	do := dialOptions{}
+	for _, option := range options {
+		option.f(&do)
+	}
+	sdo := dialOptions{
+		readTimeout:    do.readTimeout,
+		writeTimeout:   do.writeTimeout,
+		connectTimeout: do.connectTimeout,
+		keepAlive:      do.keepAlive,
+		netDial:        do.netDial,
+		setNoDelay:     do.setNoDelay,
+		noDelay:        do.noDelay,
+	}
+	if len(sentinelAddrs) == 0 {
+		return nil, errors.New("redigo: DialSentinel requires at least one Sentinel address")
+	}
+	var failures []string
+	for _, sentinelAddr := range sentinelAddrs {
+		c, err := dialSentinelMaster(&do, &sdo, sentinelAddr, masterName)
+		if err != nil {
+			failures = append(failures, sentinelAddr+": "+err.Error())
+			continue
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("redigo: no Sentinel reported a master for %s (%s)", masterName, strings.Join(failures, "; "))
+}
+
+// dialSentinelMaster dials the master reported by the Sentinel at
+// sentinelAddr.
+func dialSentinelMaster(do, sdo *dialOptions, sentinelAddr, masterName string) (Conn, error) {
+	ctx := context.Background()
+	netConn, err := sdo.dial(ctx, "tcp", sentinelAddr)
+	if err != nil {
+		return nil, err
+	}
+	sc := newConn(netConn, sdo)
+	addr, err := SentinelGetMasterAddr(sc, masterName)
+	sc.Close()
+	if err == ErrNil {
+		return nil, errors.New("master not known")
+	}
+	if err != nil {
+		return nil, err
+	}
+	netConn, err = do.dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newConnWithOptions(ctx, netConn, do)
+	if err != nil {
+		return nil, err
+	}
+	if do.verifyRole {
+		role, err := Values(c.Do("ROLE"))
+		if err == nil && len(role) == 0 {
+			err = errors.New("empty reply to ROLE")
+		}
+		var kind string
+		if err == nil {
+			kind, err = String(role[0], nil)
+		}
+		if err == nil && kind != "master" {
+			err = fmt.Errorf("%s has role %s", addr, kind)
+		}
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
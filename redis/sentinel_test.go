@@ -0,0 +1,133 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func sentinelServer(t *testing.T, masters map[string]string) *fakeServer {
+	return newFakeServer(t, func(args []string) string {
+		if len(args) != 3 || args[0] != "SENTINEL" || args[1] != "get-master-addr-by-name" {
+			return "-ERR unknown command\r\n"
+		}
+		reply, ok := masters[args[2]]
+		if !ok {
+			return "*-1\r\n"
+		}
+		return reply
+	})
+}
+
+func TestSentinelGetMasterAddr(t *testing.T) {
+	s := sentinelServer(t, map[string]string{
+		"mymaster": "*2\r\n$9\r\n127.0.0.1\r\n$4\r\n6379\r\n",
+		"ipv6":     "*2\r\n$3\r\n::1\r\n$4\r\n6380\r\n",
+		"bad":      "*1\r\n$9\r\n127.0.0.1\r\n",
+	})
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		name string
+		addr string
+		err  error
+	}{
+		{"mymaster", "127.0.0.1:6379", nil},
+		{"ipv6", "[::1]:6380", nil},
+		{"unknown", "", redis.ErrNil},
+	}
+	for _, tt := range tests {
+		addr, err := redis.SentinelGetMasterAddr(c, tt.name)
+		if addr != tt.addr || err != tt.err {
+			t.Errorf("SentinelGetMasterAddr(%s) = %q, %v, want %q, %v", tt.name, addr, err, tt.addr, tt.err)
+		}
+	}
+	if _, err := redis.SentinelGetMasterAddr(c, "bad"); err == nil {
+		t.Error("SentinelGetMasterAddr(bad) did not return error")
+	}
+
+	expected := []string{"SENTINEL", "get-master-addr-by-name", "mymaster"}
+	if actual := s.received()[0]; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("command = %v, want %v", actual, expected)
+	}
+}
+
+func TestDialSentinel(t *testing.T) {
+	master := newFakeServer(t, func(args []string) string {
+		if args[0] == "ROLE" {
+			return "*3\r\n$6\r\nmaster\r\n:0\r\n*0\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer master.close()
+	replica := newFakeServer(t, func(args []string) string {
+		if args[0] == "ROLE" {
+			return "*5\r\n$5\r\nslave\r\n$9\r\n127.0.0.1\r\n:6379\r\n$9\r\nconnected\r\n:0\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer replica.close()
+
+	masterReply := func(s *fakeServer) string {
+		host, port, _ := net.SplitHostPort(s.addr())
+		return fmt.Sprintf("*2\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(host), host, len(port), port)
+	}
+
+	down := newFakeServer(t, nil)
+	down.close()
+	unknown := sentinelServer(t, nil)
+	defer unknown.close()
+	stale := sentinelServer(t, map[string]string{"mymaster": masterReply(replica)})
+	defer stale.close()
+	good := sentinelServer(t, map[string]string{"mymaster": masterReply(master)})
+	defer good.close()
+
+	sentinels := []string{down.addr(), unknown.addr(), stale.addr(), good.addr()}
+	c, err := redis.DialSentinel(sentinels, "mymaster", redis.DialVerifyMasterRole(), redis.DialDatabase(2))
+	if err != nil {
+		t.Fatalf("DialSentinel returned %v", err)
+	}
+	defer c.Close()
+
+	expected := [][]string{{"SELECT", "2"}, {"ROLE"}}
+	if actual := master.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("master commands = %v, want %v", actual, expected)
+	}
+	if actual := good.received(); len(actual) != 1 {
+		t.Errorf("sentinel commands = %v, want one command", actual)
+	}
+
+	// Without role verification, the stale Sentinel's master is used.
+	c, err = redis.DialSentinel([]string{stale.addr()}, "mymaster")
+	if err != nil {
+		t.Fatalf("DialSentinel without role verification returned %v", err)
+	}
+	c.Close()
+
+	if _, err := redis.DialSentinel([]string{down.addr(), unknown.addr()}, "mymaster"); err == nil {
+		t.Error("DialSentinel with no usable Sentinel returned nil error")
+	}
+}
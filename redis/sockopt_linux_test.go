@@ -0,0 +1,57 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"net"
+	"syscall"
+	"time"
+)
+
+// getsockoptInt returns the value of an integer socket option on c.
+func getsockoptInt(c *net.TCPConn, level, opt int) (int, error) {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var v int
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		v, serr = syscall.GetsockoptInt(int(fd), level, opt)
+	}); err != nil {
+		return 0, err
+	}
+	return v, serr
+}
+
+// tcpKeepAlive returns whether keep-alive is enabled on c and the idle time
+// before the first keep-alive probe.
+func tcpKeepAlive(c *net.TCPConn) (bool, time.Duration, error) {
+	on, err := getsockoptInt(c, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)
+	if err != nil {
+		return false, 0, err
+	}
+	idle, err := getsockoptInt(c, syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE)
+	if err != nil {
+		return false, 0, err
+	}
+	return on != 0, time.Duration(idle) * time.Second, nil
+}
+
+// tcpNoDelay returns whether TCP_NODELAY is set on c.
+func tcpNoDelay(c *net.TCPConn) (bool, error) {
+	v, err := getsockoptInt(c, syscall.IPPROTO_TCP, syscall.TCP_NODELAY)
+	return v != 0, err
+}
@@ -0,0 +1,151 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+)
+
+// WithTimeout returns a connection that sets read and write deadlines on the
+// network connection underlying c before each call to Do, Send, Flush, Receive
+// and the optional connection methods used by the package helpers such as
+// DoContext and Ping. The read timeout applies to reading replies and the write
+// timeout applies to writing commands. A zero timeout disables the
+// corresponding deadline. Timeouts specified when c was dialed take precedence
+// over the timeouts specified here. Deadlines are cleared when each call
+// returns.
+//
+// The network connection is obtained with the NetConn function. If c does not
+// expose a network connection, then WithTimeout returns an error and the
+// application remains responsible for closing c.
+func WithTimeout(c Conn, readTimeout, writeTimeout time.Duration) (Conn, error) {
+	nc, err := NetConn(c)
+	if err != nil {
+		return nil, err
+	}
+	return &timeoutConn{Conn: c, nc: nc, readTimeout: readTimeout, writeTimeout: writeTimeout}, nil
+}
+
+type timeoutConn struct {
+	Conn
+	nc           net.Conn
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// setReadDeadline sets the read deadline for a call and returns a function
+// that clears the deadline when the call completes.
+func (c *timeoutConn) setReadDeadline() func() {
+	if c.readTimeout == 0 {
+		return func() {}
+	}
+	c.nc.SetReadDeadline(time.Now().Add(c.readTimeout))
+	return func() { c.nc.SetReadDeadline(time.Time{}) }
+}
+
+// setWriteDeadline sets the write deadline for a call and returns a function
+// that clears the deadline when the call completes.
+func (c *timeoutConn) setWriteDeadline() func() {
+	if c.writeTimeout == 0 {
+		return func() {}
+	}
+	c.nc.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	return func() { c.nc.SetWriteDeadline(time.Time{}) }
+}
+
+func (c *timeoutConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	defer c.setWriteDeadline()()
+	defer c.setReadDeadline()()
+	return c.Conn.Do(commandName, args...)
+}
+
+func (c *timeoutConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	defer c.setWriteDeadline()()
+	return DoWithTimeout(c.Conn, timeout, commandName, args...)
+}
+
+func (c *timeoutConn) DoContext(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	defer c.setWriteDeadline()()
+	defer c.setReadDeadline()()
+	return DoContext(c.Conn, ctx, commandName, args...)
+}
+
+func (c *timeoutConn) DoRaw(serialized []byte) (interface{}, error) {
+	defer c.setWriteDeadline()()
+	defer c.setReadDeadline()()
+	return DoRaw(c.Conn, serialized)
+}
+
+func (c *timeoutConn) Ping() error {
+	defer c.setWriteDeadline()()
+	defer c.setReadDeadline()()
+	return Ping(c.Conn)
+}
+
+func (c *timeoutConn) Send(commandName string, args ...interface{}) error {
+	defer c.setWriteDeadline()()
+	return c.Conn.Send(commandName, args...)
+}
+
+func (c *timeoutConn) SendInline(parts ...string) error {
+	defer c.setWriteDeadline()()
+	return SendInline(c.Conn, parts...)
+}
+
+func (c *timeoutConn) Flush() error {
+	defer c.setWriteDeadline()()
+	return c.Conn.Flush()
+}
+
+func (c *timeoutConn) Receive() (interface{}, error) {
+	defer c.setReadDeadline()()
+	return c.Conn.Receive()
+}
+
+func (c *timeoutConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return ReceiveWithTimeout(c.Conn, timeout)
+}
+
+func (c *timeoutConn) ReceiveWithKind() (byte, interface{}, error) {
+	defer c.setReadDeadline()()
+	return ReceiveWithKind(c.Conn)
+}
+
+// ReceiveReader applies the read timeout to reading the start of the reply.
+// Reads from the returned reader are not subject to the timeout.
+func (c *timeoutConn) ReceiveReader() (io.Reader, error) {
+	defer c.setReadDeadline()()
+	return ReceiveReader(c.Conn)
+}
+
+func (c *timeoutConn) CopyReply(w io.Writer) error {
+	defer c.setReadDeadline()()
+	return CopyReply(c.Conn, w)
+}
+
+func (c *timeoutConn) OnPush(f func(push []interface{})) {
+	OnPush(c.Conn, f)
+}
+
+func (c *timeoutConn) Discard() error {
+	return Discard(c.Conn)
+}
+
+func (c *timeoutConn) NetConn() net.Conn {
+	return c.nc
+}
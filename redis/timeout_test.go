@@ -0,0 +1,112 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestWithTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen returned %v", err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				time.Sleep(time.Second)
+				c.Write([]byte("+OK\r\n"))
+				c.Close()
+			}()
+		}
+	}()
+
+	nc, err := net.Dial(l.Addr().Network(), l.Addr().String())
+	if err != nil {
+		t.Fatalf("net.Dial returned %v", err)
+	}
+	c, err := redis.WithTimeout(redis.NewConn(nc, 0, 0), time.Millisecond, time.Second)
+	if err != nil {
+		t.Fatalf("WithTimeout returned %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Do("PING"); err == nil {
+		t.Fatalf("Do did not return error.")
+	}
+
+	nc, err = net.Dial(l.Addr().Network(), l.Addr().String())
+	if err != nil {
+		t.Fatalf("net.Dial returned %v", err)
+	}
+	c, err = redis.WithTimeout(redis.NewConn(nc, 0, 0), time.Millisecond, 0)
+	if err != nil {
+		t.Fatalf("WithTimeout returned %v", err)
+	}
+	defer c.Close()
+
+	c.Send("PING")
+	c.Flush()
+	if _, err := c.Receive(); err == nil {
+		t.Fatalf("Receive did not return error.")
+	}
+
+	s := newFakeServer(t, func(args []string) string { return "+PONG\r\n" })
+	defer s.close()
+	nc, err = net.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("net.Dial returned %v", err)
+	}
+	rc := redis.NewConn(nc, 0, 0)
+	c, err = redis.WithTimeout(rc, 50*time.Millisecond, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WithTimeout returned %v", err)
+	}
+	defer c.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := c.Do("PING"); err != nil {
+			t.Fatalf("Do returned %v", err)
+		}
+		time.Sleep(60 * time.Millisecond)
+	}
+	if err := redis.Ping(c); err != nil {
+		t.Fatalf("Ping returned %v", err)
+	}
+
+	// The deadlines are cleared after each call.
+	time.Sleep(60 * time.Millisecond)
+	if _, err := rc.Do("PING"); err != nil {
+		t.Fatalf("Do on underlying connection returned %v", err)
+	}
+
+	if _, err := redis.DoWithTimeout(c, time.Second, "PING"); err != nil {
+		t.Fatalf("DoWithTimeout returned %v", err)
+	}
+
+	if _, err := redis.WithTimeout(redis.NewConnBufio(bufio.ReadWriter{}), time.Second, time.Second); err == nil {
+		t.Errorf("WithTimeout on connection without network connection did not return error")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+)
+
+// ErrTxAborted is returned by Transaction when the server aborts the
+// transaction because a WATCHed key was modified.
+var ErrTxAborted = errors.New("redigo: transaction aborted")
+
+// Tx queues commands in a transaction started by the Transaction function.
+type Tx struct {
+	c Conn
+}
+
+// Send queues the command in the transaction. The command is executed when
+// the transaction function returns.
+func (tx *Tx) Send(commandName string, args ...interface{}) error {
+	return tx.c.Send(commandName, args...)
+}
+
+// Transaction executes the commands queued by f in a MULTI/EXEC transaction.
+// Transaction sends MULTI, calls f to queue commands with tx.Send and then
+// sends EXEC. The QUEUED replies to the queued commands are consumed by
+// Transaction. The reply to EXEC is returned as a []interface{} with one
+// element per queued command. Elements of the reply are redis.Error values
+// for commands that failed during execution.
+//
+// If f returns an error, then Transaction sends DISCARD and returns the error
+// from f.
+//
+// If the server rejects a queued command, then the server aborts the
+// transaction and Transaction returns the error for the rejected command. If
+// the transaction is aborted because a WATCHed key was modified, then
+// Transaction returns nil, ErrTxAborted.
+//
+// Use WATCH with Transaction to implement optimistic locking. The following
+// example retries a check-and-set until the transaction is not aborted:
+//
+//  for {
+//      if _, err := c.Do("WATCH", "counter"); err != nil {
+//          return err
+//      }
+//      n, err := redis.Int(c.Do("GET", "counter"))
+//      if err != nil && err != redis.ErrNil {
+//          return err
+//      }
+//      _, err = redis.Transaction(c, func(tx *redis.Tx) error {
+//          return tx.Send("SET", "counter", n+1)
+//      })
+//      if err != redis.ErrTxAborted {
+//          return err
+//      }
+//  }
+func Transaction(c Conn, f func(tx *Tx) error) ([]interface{}, error) {
+	if err := c.Send("MULTI"); err != nil {
+		return nil, err
+	}
+	if err := f(&Tx{c}); err != nil {
+		c.Do("DISCARD")
+		return nil, err
+	}
+	reply, err := c.Do("EXEC")
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrTxAborted
+	}
+	return Values(reply, nil)
+}
@@ -0,0 +1,109 @@
+// Copyright 2012 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"reflect"
+	"testing"
+)
+
+func transactionServer(t *testing.T, exec string) *fakeServer {
+	return newFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "MULTI", "DISCARD":
+			return "+OK\r\n"
+		case "EXEC":
+			return exec
+		}
+		return "+QUEUED\r\n"
+	})
+}
+
+func TestTransaction(t *testing.T) {
+	s := transactionServer(t, "*2\r\n+OK\r\n:1\r\n")
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	replies, err := redis.Transaction(c, func(tx *redis.Tx) error {
+		tx.Send("SET", "foo", "bar")
+		tx.Send("INCR", "counter")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transaction returned error %v", err)
+	}
+	if expected := []interface{}{"OK", int64(1)}; !reflect.DeepEqual(replies, expected) {
+		t.Errorf("Transaction returned %v, want %v", replies, expected)
+	}
+
+	expected := [][]string{{"MULTI"}, {"SET", "foo", "bar"}, {"INCR", "counter"}, {"EXEC"}}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+}
+
+func TestTransactionDiscard(t *testing.T) {
+	s := transactionServer(t, "*0\r\n")
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	errAbort := errors.New("abort")
+	_, err = redis.Transaction(c, func(tx *redis.Tx) error {
+		tx.Send("SET", "foo", "bar")
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("Transaction returned error %v, want %v", err, errAbort)
+	}
+
+	expected := [][]string{{"MULTI"}, {"SET", "foo", "bar"}, {"DISCARD"}}
+	if actual := s.received(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("commands = %v, want %v", actual, expected)
+	}
+
+	if _, err := c.Do("PING"); err != nil {
+		t.Errorf("Do(PING) after DISCARD returned %v", err)
+	}
+}
+
+func TestTransactionAborted(t *testing.T) {
+	s := transactionServer(t, "*-1\r\n")
+	defer s.close()
+
+	c, err := redis.Dial("tcp", s.addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+
+	_, err = redis.Transaction(c, func(tx *redis.Tx) error {
+		return tx.Send("SET", "foo", "bar")
+	})
+	if err != redis.ErrTxAborted {
+		t.Fatalf("Transaction returned error %v, want %v", err, redis.ErrTxAborted)
+	}
+}